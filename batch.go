@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"gthub.com/obzva/image-resize/job"
+)
+
+// runs the job files passed as arguments
+func runBatch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "usage: image-resize batch [flags] job-file...\n\nRuns JSON or YAML job files describing inputs, output targets and their operations, see the README for their format.\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	dryRunPtr := fs.Bool("dry-run", false, "print the resolved plan without processing anything, defaults to false when omitted")
+	verbosePtr := fs.Bool("v", false, "print how long every step took on stderr, defaults to false when omitted")
+
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("at least one job file is required")
+	}
+
+	for _, path := range fs.Args() {
+		if err := runJob(path, *dryRunPtr, *verbosePtr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runs the tasks of the job file at path one after another, or only prints them with dryRun
+func runJob(path string, dryRun, verbose bool) error {
+	j, err := job.Load(path)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := j.Plan()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tasks {
+		if dryRun {
+			fmt.Println(t)
+			continue
+		}
+
+		timings, err := t.Run(context.Background())
+		if err != nil {
+			return err
+		}
+		if verbose {
+			fmt.Fprintf(os.Stderr, "%s -> %s\n", t.Input, t.Output)
+			for _, timing := range timings {
+				fmt.Fprintln(os.Stderr, "  "+timing.String())
+			}
+		}
+	}
+
+	return nil
+}
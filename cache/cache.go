@@ -0,0 +1,241 @@
+// Package cache stores results on disk under content-addressed keys, and evicts the least recently used ones
+// once they take more than a size limit, so that the same output isn't computed twice.
+// The keys double as ETags, and NotModified answers conditional requests for them without any processing.
+//
+// Every result is one file named by its key, whose modification time is the last time it was used,
+// so that the order of eviction survives restarts.
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is an on-disk LRU cache of results, safe for concurrent use
+type Cache struct {
+	dir   string
+	limit int64 // largest total size of the results in bytes
+
+	mu    sync.Mutex
+	size  int64                    // total size of the results in bytes
+	lru   *list.List               // of *entry, most recently used first
+	items map[string]*list.Element // by key
+}
+
+type entry struct {
+	key  string
+	size int64
+}
+
+// Key returns the key of the result computed from parts, the hex SHA-256 of all of them
+// every part is length-prefixed, so that moving bytes from one part to the next changes the key
+func Key(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		fmt.Fprintf(h, "%d:", len(p))
+		h.Write(p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// reports whether name can be a key returned by Key
+func isKey(name string) bool {
+	if len(name) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// New opens the cache in dir, creating dir when it doesn't exist, and keeps the results already in it
+// limit is the largest total size of the results in bytes, the least recently used ones are evicted beyond it
+func New(dir string, limit int64) (*Cache, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("cache: invalid size limit %d, expected a positive one", limit)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	c := &Cache{dir: dir, limit: limit, lru: list.New(), items: make(map[string]*list.Element)}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type stored struct {
+		entry
+		used time.Time
+	}
+	var results []stored
+	for _, f := range files {
+		if !f.Type().IsRegular() {
+			continue
+		}
+		// files left behind by interrupted writes
+		if !isKey(f.Name()) {
+			os.Remove(filepath.Join(dir, f.Name()))
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		results = append(results, stored{entry{f.Name(), info.Size()}, info.ModTime()})
+	}
+
+	// most recently used first
+	slices.SortFunc(results, func(a, b stored) int {
+		return b.used.Compare(a.used)
+	})
+	for _, r := range results {
+		e := r.entry
+		c.items[e.key] = c.lru.PushBack(&e)
+		c.size += e.size
+	}
+
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+
+	return c, nil
+}
+
+// Get returns the result stored under key and whether there is one, and marks it as the most recently used
+func (c *Cache) Get(key string) ([]byte, bool) {
+	if !isKey(key) {
+		return nil, false
+	}
+
+	// read under the lock, so that no eviction removes the file between the lookup and the read
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	path := filepath.Join(c.dir, key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// removed behind the back of the cache
+		c.remove(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+
+	// the modification time keeps the order of eviction across restarts
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return data, true
+}
+
+// Put stores data under key as the most recently used result, and evicts the least recently used ones beyond the limit
+// results larger than the limit are not stored
+func (c *Cache) Put(key string, data []byte) error {
+	if !isKey(key) {
+		return fmt.Errorf("cache: invalid key %q", key)
+	}
+	if int64(len(data)) > c.limit {
+		return nil
+	}
+
+	// written aside and renamed, so that readers never see a partial result
+	f, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	// renamed under the lock, so that the file and the index always agree on the result and its size
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.Rename(f.Name(), filepath.Join(c.dir, key)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry)
+		c.size += int64(len(data)) - e.size
+		e.size = int64(len(data))
+		c.lru.MoveToFront(el)
+	} else {
+		c.items[key] = c.lru.PushFront(&entry{key, int64(len(data))})
+		c.size += int64(len(data))
+	}
+	c.evict()
+
+	return nil
+}
+
+// Size returns the total size of the results in bytes
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size
+}
+
+// removes the least recently used results until the cache fits its limit, c.mu must be held
+func (c *Cache) evict() {
+	for c.size > c.limit {
+		el := c.lru.Back()
+		if err := os.Remove(filepath.Join(c.dir, el.Value.(*entry).key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			// kept in the index, so that the size stays true, and tried again on the next eviction
+			c.lru.MoveToFront(el)
+			return
+		}
+		c.remove(el)
+	}
+}
+
+// forgets the result of el, c.mu must be held
+func (c *Cache) remove(el *list.Element) {
+	e := el.Value.(*entry)
+	c.lru.Remove(el)
+	delete(c.items, e.key)
+	c.size -= e.size
+}
+
+// NotModified reports whether the client of r already has the result of key, last modified at modTime
+// key is compared to the entity tags of If-None-Match, which takes precedence over If-Modified-Since as in RFC 9110,
+// and If-Modified-Since is ignored for results without a modification time, whose modTime is zero
+func NotModified(r *http.Request, key string, modTime time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == `"`+key+`"` {
+				return true
+			}
+		}
+		return false
+	}
+
+	if modTime.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modTime.Truncate(time.Second).After(since)
+}
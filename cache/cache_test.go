@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	a := Key([]byte("source"), []byte("w:10"))
+	if a != Key([]byte("source"), []byte("w:10")) {
+		t.Error("expected the same parts to give the same key")
+	}
+	if a == Key([]byte("sourcew"), []byte(":10")) || a == Key([]byte("source"), []byte("w:20")) {
+		t.Error("expected different parts to give different keys")
+	}
+	if !isKey(a) {
+		t.Errorf("expected %q to be a valid key", a)
+	}
+}
+
+func TestGetPut(t *testing.T) {
+	c, err := New(t.TempDir(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := Key([]byte("a"))
+	if _, ok := c.Get(key); ok {
+		t.Error("expected a miss in an empty cache but instead got a hit")
+	}
+
+	if err := c.Put(key, []byte("result")); err != nil {
+		t.Fatal(err)
+	}
+	data, ok := c.Get(key)
+	if !ok || string(data) != "result" {
+		t.Errorf("expected a hit with %q but instead got %t and %q", "result", ok, data)
+	}
+
+	// replacing a result updates the size
+	if err := c.Put(key, []byte("longer result")); err != nil {
+		t.Fatal(err)
+	}
+	if c.Size() != int64(len("longer result")) {
+		t.Errorf("expected a size of %d but instead got %d", len("longer result"), c.Size())
+	}
+
+	// results larger than the limit are not stored
+	large := Key([]byte("large"))
+	if err := c.Put(large, bytes.Repeat([]byte{1}, 101)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(large); ok {
+		t.Error("expected a result larger than the limit not to be stored but instead got a hit")
+	}
+
+	if err := c.Put("../escape", []byte("result")); err == nil {
+		t.Error("expected an error for an invalid key but instead got nil")
+	}
+}
+
+func TestEviction(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b, d := Key([]byte("a")), Key([]byte("b")), Key([]byte("d"))
+	for _, key := range []string{a, b} {
+		if err := c.Put(key, []byte("1234")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a is used after b, so b is the least recently used once d doesn't fit
+	c.Get(a)
+	if err := c.Put(d, []byte("1234")); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]bool{a: true, b: false, d: true} {
+		if _, ok := c.Get(key); ok != want {
+			t.Errorf("expected a hit for %s to be %t but instead got %t", key[:8], want, ok)
+		}
+		if _, err := os.Stat(filepath.Join(dir, key)); (err == nil) != want {
+			t.Errorf("expected the file of %s to exist to be %t but instead got %v", key[:8], want, err)
+		}
+	}
+	if c.Size() != 8 {
+		t.Errorf("expected a size of 8 but instead got %d", c.Size())
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := Key([]byte("a")), Key([]byte("b"))
+	for _, key := range []string{a, b} {
+		if err := c.Put(key, []byte("1234")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a was used last, so b goes first
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, b), old, old)
+	os.WriteFile(filepath.Join(dir, ".tmp-123"), []byte("partial"), 0o644)
+
+	// reopening with a smaller limit evicts the least recently used result
+	c, err = New(dir, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(a); !ok {
+		t.Error("expected a hit for the most recently used result but instead got a miss")
+	}
+	if _, ok := c.Get(b); ok {
+		t.Error("expected the least recently used result to be evicted but instead got a hit")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".tmp-123")); err == nil {
+		t.Error("expected partial files to be removed but instead it is still there")
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// puts and gets racing with evictions
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				key := Key([]byte{byte(g), byte(i % 7)})
+				if i%2 == 0 {
+					if err := c.Put(key, bytes.Repeat([]byte{byte(i)}, 1+i%9)); err != nil {
+						t.Error(err)
+					}
+				} else if data, ok := c.Get(key); ok && len(data) == 0 {
+					t.Errorf("expected a hit to return the result but instead got %q", data)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the size is the one of the files left
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var size int64
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += info.Size()
+	}
+	if c.Size() != size || size > 40 {
+		t.Errorf("expected a size of %d within the limit of 40 but instead got %d", size, c.Size())
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	key := Key([]byte("a"))
+	modTime := time.Date(2025, 1, 2, 3, 4, 5, 600, time.UTC)
+
+	for _, c := range []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{"no header", nil, false},
+		{"If-None-Match", http.Header{"If-None-Match": {`"` + key + `"`}}, true},
+		{"weak If-None-Match", http.Header{"If-None-Match": {`W/"` + key + `"`}}, true},
+		{"If-None-Match list", http.Header{"If-None-Match": {`"other", "` + key + `"`}}, true},
+		{"If-None-Match wildcard", http.Header{"If-None-Match": {"*"}}, true},
+		{"stale If-None-Match", http.Header{"If-None-Match": {`"other"`}}, false},
+		{"If-Modified-Since", http.Header{"If-Modified-Since": {modTime.Format(http.TimeFormat)}}, true},
+		{"old If-Modified-Since", http.Header{"If-Modified-Since": {modTime.Add(-time.Second).Format(http.TimeFormat)}}, false},
+		// If-None-Match takes precedence
+		{"stale If-None-Match and If-Modified-Since", http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {modTime.Format(http.TimeFormat)}}, false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for k, v := range c.header {
+			r.Header[k] = v
+		}
+		if got := NotModified(r, key, modTime); got != c.want {
+			t.Errorf("%s: expected %t but instead got %t", c.name, c.want, got)
+		}
+	}
+
+	// results without a modification time are only matched by their key
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("If-Modified-Since", modTime.Format(http.TimeFormat))
+	if NotModified(r, key, time.Time{}) {
+		t.Error("expected If-Modified-Since to be ignored without a modification time but instead got true")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"math"
+	"os"
+
+	"gthub.com/obzva/image-resize/metrics"
+)
+
+// prints quality metrics of an image against a reference of the same size
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "usage: image-resize compare [flags] reference image\n\nPrints the MSE, PSNR, SSIM, MS-SSIM and Butteraugli-lite distance of image against reference, which must have the same size.\nMS-SSIM is n/a for images smaller than 16x16, and a Butteraugli-lite distance of 1 is the one between flat grays of 128 and 130.\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	jsonPtr := fs.Bool("json", false, "print the metrics as a JSON object instead of text, defaults to false when omitted")
+
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("expected a reference and an image")
+	}
+
+	ref, err := decodeImage(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	img, err := decodeImage(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	r, err := metrics.Compare(ref, img)
+	if err != nil {
+		return err
+	}
+
+	if *jsonPtr {
+		// JSON has no infinity nor NaN, identical images have a PSNR of null, and images too small for MS-SSIM an MS-SSIM of null
+		out := struct {
+			metrics.Result
+			PSNR   *float64 `json:"psnr"`
+			MSSSIM *float64 `json:"ms_ssim"`
+		}{Result: r}
+		if !math.IsInf(r.PSNR, 0) {
+			out.PSNR = &r.PSNR
+		}
+		if !math.IsNaN(r.MSSSIM) {
+			out.MSSSIM = &r.MSSSIM
+		}
+
+		b, err := json.Marshal(out)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	fmt.Printf("MSE:              %.4f\n", r.MSE)
+	fmt.Printf("PSNR:             %.2f dB\n", r.PSNR)
+	fmt.Printf("SSIM:             %.4f\n", r.SSIM)
+	if math.IsNaN(r.MSSSIM) {
+		fmt.Println("MS-SSIM:          n/a")
+	} else {
+		fmt.Printf("MS-SSIM:          %.4f\n", r.MSSSIM)
+	}
+	fmt.Printf("Butteraugli-lite: %.4f\n", r.ButteraugliLite)
+
+	return nil
+}
+
+// decodes the image at path
+func decodeImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return img, nil
+}
@@ -0,0 +1,281 @@
+// Package exif reads a summary of the EXIF metadata of JPEG and PNG files:
+// camera, date, orientation and exposure.
+package exif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// ErrNotFound is returned by Decode for images without EXIF metadata
+var ErrNotFound = errors.New("exif: no metadata found")
+
+// Exif is a summary of EXIF metadata, fields missing from the metadata keep their zero value
+type Exif struct {
+	Make        string  `json:"make,omitempty"`
+	Model       string  `json:"model,omitempty"`
+	Software    string  `json:"software,omitempty"`
+	DateTime    string  `json:"date_time,omitempty"`   // when the picture was taken, or else last changed, as "YYYY:MM:DD HH:MM:SS"
+	Orientation int     `json:"orientation,omitempty"` // 1 to 8, see interpolator.Orient
+	Exposure    string  `json:"exposure,omitempty"`    // exposure time in seconds, such as "1/250"
+	FNumber     float64 `json:"f_number,omitempty"`    // aperture
+	ISO         int     `json:"iso,omitempty"`
+	FocalLength float64 `json:"focal_length,omitempty"` // in millimeters
+}
+
+// tags read into Exif
+const (
+	tagMake             = 0x010f
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagSoftware         = 0x0131
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagExposureTime     = 0x829a
+	tagFNumber          = 0x829d
+	tagISO              = 0x8827
+	tagDateTimeOriginal = 0x9003
+	tagFocalLength      = 0x920a
+)
+
+// Decode reads the EXIF metadata of a JPEG or PNG image from r, which is read up to the image data
+func Decode(r io.Reader) (*Exif, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(8)
+	if err != nil && len(magic) < 2 {
+		return nil, err
+	}
+
+	var tiff []byte
+	switch {
+	case bytes.HasPrefix(magic, []byte{0xff, 0xd8}):
+		tiff, err = jpegSegment(br)
+	case bytes.Equal(magic, []byte("\x89PNG\r\n\x1a\n")):
+		tiff, err = pngChunk(br)
+	default:
+		return nil, errors.New("exif: only jpeg and png images are supported")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTIFF(tiff)
+}
+
+// returns the TIFF data of the APP1 Exif segment of a JPEG, stopping at the start of the image data
+func jpegSegment(r *bufio.Reader) ([]byte, error) {
+	if _, err := r.Discard(2); err != nil {
+		return nil, err
+	}
+
+	for {
+		var marker [4]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return nil, fmt.Errorf("exif: reading jpeg segments: %w", err)
+		}
+		if marker[0] != 0xff {
+			return nil, errors.New("exif: invalid jpeg segment")
+		}
+
+		// start of scan, the metadata segments come before it
+		if marker[1] == 0xda || marker[1] == 0xd9 {
+			return nil, ErrNotFound
+		}
+
+		n := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if n < 0 {
+			return nil, errors.New("exif: invalid jpeg segment length")
+		}
+
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("exif: reading jpeg segments: %w", err)
+		}
+
+		if tiff, ok := bytes.CutPrefix(data, []byte("Exif\x00\x00")); marker[1] == 0xe1 && ok {
+			return tiff, nil
+		}
+	}
+}
+
+// returns the data of the eXIf chunk of a PNG, stopping at the start of the image data
+func pngChunk(r *bufio.Reader) ([]byte, error) {
+	if _, err := r.Discard(8); err != nil {
+		return nil, err
+	}
+
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, fmt.Errorf("exif: reading png chunks: %w", err)
+		}
+
+		n := binary.BigEndian.Uint32(header[:4])
+		switch string(header[4:]) {
+		case "IDAT", "IEND":
+			return nil, ErrNotFound
+		case "eXIf":
+			// EXIF data is limited to 64 KB in JPEG, anything far larger is corrupt
+			if n > 1<<20 {
+				return nil, errors.New("exif: eXIf chunk too large")
+			}
+			data := make([]byte, n)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, fmt.Errorf("exif: reading png chunks: %w", err)
+			}
+			return data, nil
+		}
+
+		// skips the data and the CRC
+		if _, err := r.Discard(int(n) + 4); err != nil {
+			return nil, fmt.Errorf("exif: reading png chunks: %w", err)
+		}
+	}
+}
+
+// reads the tags of Exif from the first IFD and the EXIF IFD of TIFF data
+func parseTIFF(b []byte) (*Exif, error) {
+	if len(b) < 8 {
+		return nil, errors.New("exif: truncated tiff header")
+	}
+
+	var order binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, errors.New("exif: invalid tiff byte order")
+	}
+	if order.Uint16(b[2:]) != 42 {
+		return nil, errors.New("exif: invalid tiff header")
+	}
+
+	t := &tiff{b: b, order: order}
+	e := &Exif{}
+
+	ifd0, err := t.ifd(order.Uint32(b[4:]))
+	if err != nil {
+		return nil, err
+	}
+
+	e.Make = t.ascii(ifd0[tagMake])
+	e.Model = t.ascii(ifd0[tagModel])
+	e.Software = t.ascii(ifd0[tagSoftware])
+	e.DateTime = t.ascii(ifd0[tagDateTime])
+	e.Orientation = int(t.uint(ifd0[tagOrientation]))
+
+	if entry, ok := ifd0[tagExifIFD]; ok {
+		sub, err := t.ifd(t.uint(entry))
+		if err != nil {
+			return nil, err
+		}
+
+		if s := t.ascii(sub[tagDateTimeOriginal]); s != "" {
+			e.DateTime = s
+		}
+		// fractions of a second are written as 1/n
+		if num, den := t.rational(sub[tagExposureTime]); num != 0 && den != 0 {
+			if num < den {
+				e.Exposure = fmt.Sprintf("1/%g", math.Round(float64(den)/float64(num)*10)/10)
+			} else {
+				e.Exposure = fmt.Sprintf("%g", float64(num)/float64(den))
+			}
+		}
+		if num, den := t.rational(sub[tagFNumber]); den != 0 {
+			e.FNumber = float64(num) / float64(den)
+		}
+		e.ISO = int(t.uint(sub[tagISO]))
+		if num, den := t.rational(sub[tagFocalLength]); den != 0 {
+			e.FocalLength = float64(num) / float64(den)
+		}
+	}
+
+	return e, nil
+}
+
+// TIFF data and its byte order
+type tiff struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+// raw IFD entry
+type entry struct {
+	typ   uint16
+	count uint32
+	value []byte // the 4 bytes holding the value or its offset
+}
+
+// sizes in bytes of the TIFF types
+var typeSizes = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+// reads the entries of the IFD at offset by tag
+func (t *tiff) ifd(offset uint32) (map[uint16]entry, error) {
+	if uint64(offset)+2 > uint64(len(t.b)) {
+		return nil, errors.New("exif: ifd out of bounds")
+	}
+
+	n := uint32(t.order.Uint16(t.b[offset:]))
+	if uint64(offset)+2+12*uint64(n) > uint64(len(t.b)) {
+		return nil, errors.New("exif: ifd out of bounds")
+	}
+
+	entries := make(map[uint16]entry, n)
+	for i := range n {
+		e := t.b[offset+2+12*i:]
+		entries[t.order.Uint16(e)] = entry{typ: t.order.Uint16(e[2:]), count: t.order.Uint32(e[4:]), value: e[8:12]}
+	}
+
+	return entries, nil
+}
+
+// returns the bytes of the value of e, nil when it is out of bounds
+func (t *tiff) data(e entry) []byte {
+	size := uint64(typeSizes[e.typ]) * uint64(e.count)
+	if size <= 4 {
+		return e.value[:size]
+	}
+
+	offset := uint64(t.order.Uint32(e.value))
+	if offset+size > uint64(len(t.b)) {
+		return nil
+	}
+	return t.b[offset : offset+size]
+}
+
+func (t *tiff) ascii(e entry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(t.data(e)), "\x00"))
+}
+
+// reads SHORT and LONG values, 0 for any other type
+func (t *tiff) uint(e entry) uint32 {
+	d := t.data(e)
+	switch {
+	case e.typ == 3 && len(d) >= 2:
+		return uint32(t.order.Uint16(d))
+	case e.typ == 4 && len(d) >= 4:
+		return t.order.Uint32(d)
+	}
+	return 0
+}
+
+// reads RATIONAL values, 0/0 for any other type
+func (t *tiff) rational(e entry) (num, den uint32) {
+	d := t.data(e)
+	if e.typ != 5 || len(d) < 8 {
+		return 0, 0
+	}
+	return t.order.Uint32(d), t.order.Uint32(d[4:])
+}
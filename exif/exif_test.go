@@ -0,0 +1,136 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// big-endian TIFF data with the tags of the summary
+func sampleTIFF() []byte {
+	var b bytes.Buffer
+	be := binary.BigEndian
+
+	// header, IFD0 at 8 with 4 entries (2 + 4*12 + 4 bytes), the EXIF IFD at 62 with 4 entries, and the values from 116
+	b.WriteString("MM")
+	binary.Write(&b, be, uint16(42))
+	binary.Write(&b, be, uint32(8))
+
+	values := 116
+	var data bytes.Buffer
+	entry := func(tag, typ uint16, count uint32, value []byte) {
+		binary.Write(&b, be, tag)
+		binary.Write(&b, be, typ)
+		binary.Write(&b, be, count)
+		if len(value) <= 4 {
+			b.Write(append(value, make([]byte, 4-len(value))...))
+			return
+		}
+		binary.Write(&b, be, uint32(values+data.Len()))
+		data.Write(value)
+	}
+	rational := func(num, den uint32) []byte {
+		return be.AppendUint32(be.AppendUint32(nil, num), den)
+	}
+
+	binary.Write(&b, be, uint16(4))
+	entry(tagMake, 2, 6, []byte("Canon\x00"))
+	entry(tagModel, 2, 10, []byte("EOS R5 \x00\x00\x00"))
+	entry(tagOrientation, 3, 1, be.AppendUint16(nil, 6))
+	entry(tagExifIFD, 4, 1, be.AppendUint32(nil, 62))
+	binary.Write(&b, be, uint32(0))
+
+	binary.Write(&b, be, uint16(4))
+	entry(tagExposureTime, 5, 1, rational(10, 2500))
+	entry(tagFNumber, 5, 1, rational(28, 10))
+	entry(tagISO, 3, 1, be.AppendUint16(nil, 400))
+	entry(tagDateTimeOriginal, 2, 20, []byte("2024:05:01 12:30:00\x00"))
+	binary.Write(&b, be, uint32(0))
+
+	return append(b.Bytes(), data.Bytes()...)
+}
+
+func check(t *testing.T, name string, e *Exif) {
+	expected := Exif{Make: "Canon", Model: "EOS R5", Orientation: 6, DateTime: "2024:05:01 12:30:00", Exposure: "1/250", FNumber: 2.8, ISO: 400}
+	if *e != expected {
+		t.Errorf("%s: expected %+v but instead got %+v", name, expected, *e)
+	}
+}
+
+func TestDecodeJPEG(t *testing.T) {
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Decode(bytes.NewReader(img.Bytes())); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound but instead got %v", err)
+	}
+
+	// APP1 right after the start of image
+	payload := append([]byte("Exif\x00\x00"), sampleTIFF()...)
+	segment := append([]byte{0xff, 0xe1}, binary.BigEndian.AppendUint16(nil, uint16(len(payload)+2))...)
+	withExif := append(append(append([]byte{}, img.Bytes()[:2]...), append(segment, payload...)...), img.Bytes()[2:]...)
+
+	e, err := Decode(bytes.NewReader(withExif))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t, "jpeg", e)
+
+	// the image still decodes
+	if _, err := jpeg.Decode(bytes.NewReader(withExif)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodePNG(t *testing.T) {
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Decode(bytes.NewReader(img.Bytes())); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound but instead got %v", err)
+	}
+
+	// eXIf right after the 8-byte signature and the 25-byte IHDR chunk
+	data := sampleTIFF()
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, "eXIf"...)
+	chunk = append(chunk, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	withExif := append(append(append([]byte{}, img.Bytes()[:33]...), chunk...), img.Bytes()[33:]...)
+
+	e, err := Decode(bytes.NewReader(withExif))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t, "png", e)
+
+	if _, err := png.Decode(bytes.NewReader(withExif)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	// truncated and out-of-bounds data is an error, never a panic
+	tiff := sampleTIFF()
+	for _, n := range []int{4, 20, 70} {
+		payload := append([]byte("Exif\x00\x00"), tiff[:n]...)
+		jpg := append([]byte{0xff, 0xd8, 0xff, 0xe1}, binary.BigEndian.AppendUint16(nil, uint16(len(payload)+2))...)
+		jpg = append(jpg, payload...)
+		if _, err := Decode(bytes.NewReader(jpg)); err == nil {
+			t.Errorf("expected an error for %d bytes of tiff data but instead got nil", n)
+		}
+	}
+
+	if _, err := Decode(bytes.NewReader([]byte("GIF89a"))); err == nil {
+		t.Error("expected an error for a gif but instead got nil")
+	}
+}
@@ -0,0 +1,207 @@
+package filter
+
+import (
+	"context"
+	"image"
+	"log"
+	"math"
+
+	"gthub.com/obzva/image-resize/parallel"
+)
+
+// weights of a gaussian of standard deviation sigma from -r to r, r = ceil(3 sigma), summing up to 1
+func gaussianKernel(sigma float64) []float32 {
+	r := int(math.Ceil(3 * sigma))
+	k := make([]float32, 2*r+1)
+
+	var sum float64
+	weights := make([]float64, len(k))
+	for i := range weights {
+		d := float64(i - r)
+		weights[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += weights[i]
+	}
+	for i, w := range weights {
+		k[i] = float32(w / sum)
+	}
+
+	return k
+}
+
+// convolves every row of buf with the kernel k centered on each pixel, clamping coordinates outside of buf
+func (buf *buffer) horizontal(ctx context.Context, concurrency bool, config parallel.Config, k []float32) (*buffer, error) {
+	out := newBuffer(buf.w, buf.h)
+	r := len(k) / 2
+
+	err := parallel.RunPixels(ctx, concurrency, config, buf.w, buf.h, func(x, y int) {
+		i := y*buf.w + x
+
+		var c [4]float32
+		for j, w := range k {
+			sX := max(0, min(x+j-r, buf.w-1))
+			p := buf.pix[4*(y*buf.w+sX):]
+			c[0] += w * p[0]
+			c[1] += w * p[1]
+			c[2] += w * p[2]
+			c[3] += w * p[3]
+		}
+		copy(out.pix[4*i:4*i+4], c[:])
+	})
+
+	return out, err
+}
+
+// convolves every column of buf with the kernel k centered on each pixel, clamping coordinates outside of buf
+func (buf *buffer) vertical(ctx context.Context, concurrency bool, config parallel.Config, k []float32) (*buffer, error) {
+	out := newBuffer(buf.w, buf.h)
+	r := len(k) / 2
+
+	err := parallel.RunPixels(ctx, concurrency, config, buf.w, buf.h, func(x, y int) {
+		i := y*buf.w + x
+
+		var c [4]float32
+		for j, w := range k {
+			sY := max(0, min(y+j-r, buf.h-1))
+			p := buf.pix[4*(sY*buf.w+x):]
+			c[0] += w * p[0]
+			c[1] += w * p[1]
+			c[2] += w * p[2]
+			c[3] += w * p[3]
+		}
+		copy(out.pix[4*i:4*i+4], c[:])
+	})
+
+	return out, err
+}
+
+// averages every pixel of buf with the r pixels on either side of it in its row, clamping coordinates outside of buf
+// the sum of the window slides along the row instead of being added up for every pixel
+func (buf *buffer) box(ctx context.Context, concurrency bool, config parallel.Config, r int) (*buffer, error) {
+	out := newBuffer(buf.w, buf.h)
+	scale := 1 / float64(2*r+1)
+
+	at := func(x, y int) []float32 {
+		x = max(0, min(x, buf.w-1))
+		return buf.pix[4*(y*buf.w+x) : 4*(y*buf.w+x)+4]
+	}
+
+	// the window starts over at the beginning of every part of a row
+	err := parallel.RunRows(ctx, concurrency, config, buf.w, buf.h, func(y, x0, x1 int) {
+		var sum [4]float64
+		for sX := x0 - r; sX <= x0+r; sX++ {
+			p := at(sX, y)
+			for c := range 4 {
+				sum[c] += float64(p[c])
+			}
+		}
+
+		for x := x0; x < x1; x++ {
+			if x > x0 {
+				add, drop := at(x+r, y), at(x-r-1, y)
+				for c := range 4 {
+					sum[c] += float64(add[c]) - float64(drop[c])
+				}
+			}
+
+			i := y*buf.w + x
+			for c := range 4 {
+				out.pix[4*i+c] = float32(sum[c] * scale)
+			}
+		}
+	})
+	return out, err
+}
+
+// GaussianBlur blurs images with a gaussian of standard deviation sigma, one pass along the rows and one along the columns
+// color values are weighted by alpha, so that transparent pixels don't darken their neighbors
+type GaussianBlur struct {
+	src   *image.NRGBA
+	sigma float64
+	opts  Options
+}
+
+// initialize GaussianBlur, sigma is in pixels
+func NewGaussianBlur(src *image.NRGBA, sigma float64, opts Options) Filter {
+	if sigma <= 0 {
+		log.Fatal("wrong gaussian blur sigma passed, it has to be positive")
+	}
+
+	return &GaussianBlur{src: src, sigma: sigma, opts: opts}
+}
+
+func (gb *GaussianBlur) Apply(concurrency bool) *image.NRGBA {
+	output, _ := gb.ApplyContext(context.Background(), concurrency)
+	return output
+}
+
+func (gb *GaussianBlur) ApplyContext(ctx context.Context, concurrency bool) (*image.NRGBA, error) {
+	// only the last pass is reported as progress
+	config := gb.opts.parallel()
+	firstConfig := config
+	firstConfig.Progress = nil
+
+	k := gaussianKernel(gb.sigma)
+	buf, err := bufferOf(gb.src).horizontal(ctx, concurrency, firstConfig, k)
+	if err != nil {
+		return nil, err
+	}
+	if buf, err = buf.vertical(ctx, concurrency, config, k); err != nil {
+		return nil, err
+	}
+
+	return buf.nrgba(), nil
+}
+
+// BoxBlur blurs images by averaging the (2 radius + 1) x (2 radius + 1) pixels around each of them, passes times
+// three passes come close to a gaussian of standard deviation sqrt(radius (radius + 1)), at a cost that doesn't grow with the radius
+// color values are weighted by alpha, so that transparent pixels don't darken their neighbors
+type BoxBlur struct {
+	src            *image.NRGBA
+	radius, passes int
+	opts           Options
+}
+
+// initialize BoxBlur
+func NewBoxBlur(src *image.NRGBA, radius, passes int, opts Options) Filter {
+	if radius <= 0 || passes <= 0 {
+		log.Fatal("wrong box blur parameters passed, radius and passes have to be positive")
+	}
+
+	return &BoxBlur{src: src, radius: radius, passes: passes, opts: opts}
+}
+
+func (bb *BoxBlur) Apply(concurrency bool) *image.NRGBA {
+	output, _ := bb.ApplyContext(context.Background(), concurrency)
+	return output
+}
+
+func (bb *BoxBlur) ApplyContext(ctx context.Context, concurrency bool) (*image.NRGBA, error) {
+	// only the last pass is reported as progress
+	config := bb.opts.parallel()
+	firstConfig := config
+	firstConfig.Progress = nil
+
+	var err error
+	buf := bufferOf(bb.src)
+
+	// columns are blurred as the rows of the transposed image, so that the window slides along the flat index
+	buf = buf.transpose()
+	for range bb.passes {
+		if buf, err = buf.box(ctx, concurrency, firstConfig, bb.radius); err != nil {
+			return nil, err
+		}
+	}
+	buf = buf.transpose()
+
+	for pass := range bb.passes {
+		c := firstConfig
+		if pass == bb.passes-1 {
+			c = config
+		}
+		if buf, err = buf.box(ctx, concurrency, c, bb.radius); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.nrgba(), nil
+}
@@ -0,0 +1,103 @@
+package filter
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// 32x32 image of white noise, seeded so that it is the same every time
+func noise() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	seed := uint32(1)
+	for i := range img.Pix {
+		seed = seed*1664525 + 1013904223
+		img.Pix[i] = uint8(seed >> 24)
+		if i%4 == 3 {
+			img.Pix[i] = 255
+		}
+	}
+	return img
+}
+
+// standard deviation of the red values of img
+func deviation(img *image.NRGBA) float64 {
+	var sum, sum2 float64
+	n := float64(len(img.Pix) / 4)
+	for i := 0; i < len(img.Pix); i += 4 {
+		v := float64(img.Pix[i])
+		sum += v
+		sum2 += v * v
+	}
+	return math.Sqrt(sum2/n - (sum/n)*(sum/n))
+}
+
+func TestBlur(t *testing.T) {
+	src := noise()
+
+	blurs := map[string]func(opts Options) Filter{
+		"gaussian": func(opts Options) Filter { return NewGaussianBlur(src, 2, opts) },
+		"box":      func(opts Options) Filter { return NewBoxBlur(src, 2, 3, opts) },
+	}
+
+	for name, blur := range blurs {
+		expected := blur(Options{}).Apply(false)
+		actual := blur(Options{Workers: 3}).Apply(true)
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("%s: expected %d at index %d, same as without concurrency, but instead got %d", name, expected.Pix[i], i, actual.Pix[i])
+			}
+		}
+
+		// noise is smoothed out
+		if before, after := deviation(src), deviation(actual); after > before/3 {
+			t.Errorf("%s: expected the deviation of %.1f to shrink at least 3 times but instead got %.1f", name, before, after)
+		}
+
+		// flat colors stay the same, transparent pixels included
+		for _, c := range []color.NRGBA{{200, 30, 90, 255}, {200, 30, 90, 128}} {
+			flat := image.NewNRGBA(image.Rect(0, 0, 9, 7))
+			for i := 0; i < len(flat.Pix); i += 4 {
+				flat.Pix[i], flat.Pix[i+1], flat.Pix[i+2], flat.Pix[i+3] = c.R, c.G, c.B, c.A
+			}
+
+			var f Filter
+			if name == "gaussian" {
+				f = NewGaussianBlur(flat, 3, Options{})
+			} else {
+				f = NewBoxBlur(flat, 3, 2, Options{})
+			}
+			out := f.Apply(true)
+			for y := range 7 {
+				for x := range 9 {
+					if out.NRGBAAt(x, y) != c {
+						t.Fatalf("%s: expected %v at (%d, %d) but instead got %v", name, c, x, y, out.NRGBAAt(x, y))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestBoxBlurSum(t *testing.T) {
+	// a single white pixel on black spreads into a 5x5 square of 1/25 of its value with one pass of radius 2
+	src := image.NewNRGBA(image.Rect(0, 0, 11, 11))
+	for i := 3; i < len(src.Pix); i += 4 {
+		src.Pix[i] = 255
+	}
+	src.SetNRGBA(5, 5, color.NRGBA{250, 250, 250, 255})
+
+	actual := NewBoxBlur(src, 2, 1, Options{}).Apply(true)
+	for y := range 11 {
+		for x := range 11 {
+			var expected uint8
+			if x >= 3 && x <= 7 && y >= 3 && y <= 7 {
+				expected = 10
+			}
+			if v := actual.NRGBAAt(x, y).R; v != expected {
+				t.Errorf("expected %d at (%d, %d) but instead got %d", expected, x, y, v)
+			}
+		}
+	}
+}
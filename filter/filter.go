@@ -0,0 +1,112 @@
+// Package filter applies neighborhood filters, such as blurs and sharpening, to images.
+package filter
+
+import (
+	"context"
+	"image"
+
+	"gthub.com/obzva/image-resize/parallel"
+)
+
+// Filter is a filter bound to its source image
+type Filter interface {
+	// filters the source image into a new one
+	Apply(concurrency bool) *image.NRGBA
+	// same as Apply, but stops early and returns ctx.Err() when ctx is done
+	ApplyContext(ctx context.Context, concurrency bool) (*image.NRGBA, error)
+}
+
+// Options tunes how a Filter spreads its work, the zero value uses one goroutine per CPU
+type Options struct {
+	Workers   int                // number of goroutines in concurrency mode, defaults to runtime.NumCPU() when 0
+	Pool      *parallel.Pool     // worker pool shared with other filters and interpolations, Workers is ignored when set
+	Partition parallel.Partition // how pixels are split between workers, defaults to parallel.Flat
+
+	// optional callback receiving the number of completed rows of the last pass out of the image height
+	// throttled to one call per percent of rows, see parallel.Config
+	Progress func(done, total int)
+}
+
+func (o Options) parallel() parallel.Config {
+	return parallel.Config{
+		Workers:   o.Workers,
+		Pool:      o.Pool,
+		Partition: o.Partition,
+		Progress:  o.Progress,
+	}
+}
+
+// working copy of an image, premultiplied color values in [0, 255], 4 per pixel
+type buffer struct {
+	pix  []float32
+	w, h int
+}
+
+func newBuffer(w, h int) *buffer {
+	return &buffer{pix: make([]float32, 4*w*h), w: w, h: h}
+}
+
+// copies src into a premultiplied buffer
+func bufferOf(src *image.NRGBA) *buffer {
+	b := src.Bounds()
+	buf := newBuffer(b.Dx(), b.Dy())
+
+	for y := range buf.h {
+		row := src.Pix[y*src.Stride:]
+		for x := range buf.w {
+			s := row[4*x : 4*x+4]
+			d := buf.pix[4*(y*buf.w+x) : 4*(y*buf.w+x)+4]
+
+			a := float32(s[3]) / 255
+			d[0] = float32(s[0]) * a
+			d[1] = float32(s[1]) * a
+			d[2] = float32(s[2]) * a
+			d[3] = float32(s[3])
+		}
+	}
+
+	return buf
+}
+
+// copies buf into a new image, dividing the color values by alpha
+func (buf *buffer) nrgba() *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, buf.w, buf.h))
+
+	for i := range buf.w * buf.h {
+		s := buf.pix[4*i : 4*i+4]
+		d := dst.Pix[4*i : 4*i+4]
+
+		d[3] = clampUint8(s[3])
+		if d[3] == 0 {
+			continue
+		}
+		d[0] = clampUint8(s[0] * 255 / s[3])
+		d[1] = clampUint8(s[1] * 255 / s[3])
+		d[2] = clampUint8(s[2] * 255 / s[3])
+	}
+
+	return dst
+}
+
+// returns buf with its rows and columns swapped
+func (buf *buffer) transpose() *buffer {
+	t := newBuffer(buf.h, buf.w)
+	for y := range buf.h {
+		for x := range buf.w {
+			copy(t.pix[4*(x*t.w+y):4*(x*t.w+y)+4], buf.pix[4*(y*buf.w+x):4*(y*buf.w+x)+4])
+		}
+	}
+
+	return t
+}
+
+// rounds a color value and clamps it into [0, 255]
+func clampUint8(v float32) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
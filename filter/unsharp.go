@@ -0,0 +1,96 @@
+package filter
+
+import (
+	"context"
+	"image"
+	"log"
+	"math"
+
+	"gthub.com/obzva/image-resize/parallel"
+)
+
+// Sharpening describes the unsharp mask of NewUnsharpMask
+type Sharpening struct {
+	Radius float64 // standard deviation of the gaussian blur in pixels, larger values sharpen coarser detail
+	Amount float64 // how much of the difference from the blurred image is added back, 1 doubles it
+
+	// smallest difference from the blurred image, in 8-bit color values, that is sharpened,
+	// so that noise in flat areas is left as it is
+	Threshold float64
+}
+
+// UnsharpMask sharpens images by adding back how much each pixel differs from a gaussian blur of the image,
+// which raises the contrast of edges and fine detail, softened by downscaling for example
+// color channels are sharpened, alpha is kept, and transparent pixels don't bleed into their neighbors
+// for more detail, please refer to https://en.wikipedia.org/wiki/Unsharp_masking
+type UnsharpMask struct {
+	src  *image.NRGBA
+	s    Sharpening
+	opts Options
+}
+
+// initialize UnsharpMask
+func NewUnsharpMask(src *image.NRGBA, s Sharpening, opts Options) Filter {
+	if s.Radius <= 0 || s.Amount < 0 || s.Threshold < 0 {
+		log.Fatal("wrong unsharp mask parameters passed, radius has to be positive, amount and threshold can't be negative")
+	}
+
+	return &UnsharpMask{src: src, s: s, opts: opts}
+}
+
+func (um *UnsharpMask) Apply(concurrency bool) *image.NRGBA {
+	output, _ := um.ApplyContext(context.Background(), concurrency)
+	return output
+}
+
+func (um *UnsharpMask) ApplyContext(ctx context.Context, concurrency bool) (*image.NRGBA, error) {
+	src := bufferOf(um.src)
+	w, h := src.w, src.h
+
+	// only the last pass is reported as progress
+	config := um.opts.parallel()
+	blurConfig := config
+	blurConfig.Progress = nil
+
+	k := gaussianKernel(um.s.Radius)
+	blurred, err := src.horizontal(ctx, concurrency, blurConfig, k)
+	if err != nil {
+		return nil, err
+	}
+	if blurred, err = blurred.vertical(ctx, concurrency, blurConfig, k); err != nil {
+		return nil, err
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	amount := float32(um.s.Amount)
+	threshold := float32(um.s.Threshold)
+
+	err = parallel.RunPixels(ctx, concurrency, config, w, h, func(x, y int) {
+		i := y*w + x
+
+		s := um.src.Pix[y*um.src.Stride+4*x:]
+		d := dst.Pix[4*i : 4*i+4]
+		b := blurred.pix[4*i:]
+
+		d[3] = s[3]
+		if s[3] == 0 || b[3] == 0 {
+			copy(d[:3], s[:3])
+			return
+		}
+
+		for c := range 3 {
+			v := float32(s[c])
+			// blurred colors are premultiplied by the blurred alpha
+			diff := v - b[c]*255/b[3]
+			if float32(math.Abs(float64(diff))) >= threshold {
+				v += amount * diff
+			}
+			d[c] = clampUint8(v)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,99 @@
+package filter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"gthub.com/obzva/image-resize/parallel"
+)
+
+// w x h image whose left half is dark and right half is light
+func stepEdge(w, h int, dark, light uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			v := dark
+			if x >= w/2 {
+				v = light
+			}
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestUnsharpMask(t *testing.T) {
+	src := stepEdge(20, 6, 100, 150)
+	s := Sharpening{Radius: 1, Amount: 1}
+
+	expected := NewUnsharpMask(src, s, Options{}).Apply(false)
+	for _, opts := range []Options{{Workers: 3}, {Workers: 2, Partition: parallel.RowBand}} {
+		actual := NewUnsharpMask(src, s, opts).Apply(true)
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("expected %d at index %d, same as without concurrency, but instead got %d", expected.Pix[i], i, actual.Pix[i])
+			}
+		}
+	}
+
+	// the contrast of the edge grows on both sides of it, flat areas away from it stay the same
+	for x, want := range map[int]string{0: "same", 9: "darker", 10: "lighter", 19: "same"} {
+		for y := range 6 {
+			before, after := src.NRGBAAt(x, y), expected.NRGBAAt(x, y)
+			if after.A != 255 || after.R != after.G || after.G != after.B {
+				t.Fatalf("expected an opaque gray at (%d, %d) but instead got %v", x, y, after)
+			}
+
+			var ok bool
+			switch want {
+			case "same":
+				ok = after.R == before.R
+			case "darker":
+				ok = after.R < before.R
+			case "lighter":
+				ok = after.R > before.R
+			}
+			if !ok {
+				t.Errorf("expected the pixel at (%d, %d) to be %s than %d but instead got %d", x, y, want, before.R, after.R)
+			}
+		}
+	}
+
+	// differences below the threshold are left alone
+	low := stepEdge(20, 6, 100, 104)
+	actual := NewUnsharpMask(low, Sharpening{Radius: 1, Amount: 1, Threshold: 4}, Options{}).Apply(true)
+	for i := range low.Pix {
+		if low.Pix[i] != actual.Pix[i] {
+			t.Fatalf("expected %d at index %d, unchanged below the threshold, but instead got %d", low.Pix[i], i, actual.Pix[i])
+		}
+	}
+}
+
+func TestUnsharpMaskAlpha(t *testing.T) {
+	// an opaque red square on a transparent white background
+	src := image.NewNRGBA(image.Rect(0, 0, 12, 12))
+	for y := range 12 {
+		for x := range 12 {
+			c := color.NRGBA{255, 255, 255, 0}
+			if x >= 4 && x < 8 && y >= 4 && y < 8 {
+				c = color.NRGBA{255, 0, 0, 255}
+			}
+			src.SetNRGBA(x, y, c)
+		}
+	}
+
+	actual := NewUnsharpMask(src, Sharpening{Radius: 2, Amount: 2}, Options{}).Apply(true)
+	for y := range 12 {
+		for x := range 12 {
+			before, after := src.NRGBAAt(x, y), actual.NRGBAAt(x, y)
+			if after.A != before.A {
+				t.Fatalf("expected alpha %d at (%d, %d) but instead got %d", before.A, x, y, after.A)
+			}
+			// the transparent white doesn't make the red darker or lighter
+			if after.A == 255 && after != before {
+				t.Errorf("expected %v at (%d, %d) but instead got %v", before, x, y, after)
+			}
+		}
+	}
+}
@@ -1,3 +1,5 @@
 module gthub.com/obzva/image-resize
 
 go 1.23.2
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,25 @@
+// Package hexcolor parses colors written as hex RRGGBB or RRGGBBAA, with or without a leading #,
+// as taken by the -bg flag and by job files.
+package hexcolor
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Parse parses RRGGBB or RRGGBBAA, with or without a leading #, RRGGBB is opaque
+func Parse(s string) (color.NRGBA, error) {
+	h := strings.TrimPrefix(s, "#")
+	if len(h) == 6 {
+		h += "ff"
+	}
+
+	v, err := strconv.ParseUint(h, 16, 32)
+	if err != nil || len(h) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q, expected RRGGBB or RRGGBBAA", s)
+	}
+
+	return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
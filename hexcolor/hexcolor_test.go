@@ -0,0 +1,28 @@
+package hexcolor
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for s, want := range map[string]color.NRGBA{
+		"ff8000":    {255, 128, 0, 255},
+		"#ff8000":   {255, 128, 0, 255},
+		"0000ff80":  {0, 0, 255, 128},
+		"#FFFFFF00": {255, 255, 255, 0},
+	} {
+		got, err := Parse(s)
+		if err != nil {
+			t.Errorf("%s: expected %v but instead got %v", s, want, err)
+		} else if got != want {
+			t.Errorf("%s: expected %v but instead got %v", s, want, got)
+		}
+	}
+
+	for _, s := range []string{"", "#", "fff", "white", "ff800", "ff80000", "ff8000ff00", "+f8000"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("%s: expected an error but instead got nil", s)
+		}
+	}
+}
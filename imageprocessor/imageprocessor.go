@@ -1,42 +1,142 @@
 package imageprocessor
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
 	"image/jpeg"
 	"image/png"
+	"io"
 	"log"
 	"math"
 	"os"
 	"regexp"
 
+	"gthub.com/obzva/image-resize/filter"
 	"gthub.com/obzva/image-resize/interpolator"
 )
 
+// Stdio is the input path reading from stdin, and the output name writing to stdout
+const Stdio = "-"
+
 type ImageProcessor struct {
-	path         string       // path to the input file
-	iExt         string       // "jpeg" | "png" extension of the input file, only jpeg(jpg), png are available
-	src          *image.NRGBA // in-memory input image converted to *image.NRGBA
-	w, h         int          // width and height of output image file
-	name         string       // name of output image file
-	oExt         string       // "jpeg" | "png" extension of the output file, only jpeg(jpg), png are available
+	path         string      // path to the input file, Stdio for stdin
+	iExt         string      // "jpeg" | "png" extension of the input file, only jpeg(jpg), png are available
+	src          image.Image // in-memory input image, see readImageFile
+	w, h         int         // width and height of output image file
+	name         string      // name of output image file, Stdio for stdout
+	oExt         string      // "jpeg" | "png" extension of the output file, only jpeg(jpg), png are available
 	concurrency  bool
 	interpolator interpolator.Interpolator
+	method       string                 // interpolation method, also used to sample rotations
+	opts         interpolator.Options   // options of the interpolator, also used for rotations
+	flips        []string               // flip operations applied in order after resizing, see interpolator.Flip
+	rotation     *interpolator.Rotation // applied after resizing and flipping, nil when no rotation was asked for
+	blurs        []newFilter            // applied in order after rotating
+	sharpening   *filter.Sharpening     // unsharp mask applied last, nil when no sharpening was asked for
+}
+
+// Carve makes CreateImageFile resize by seam carving, see interpolator.SeamCarver
+// protectPath and removePath are optional mask images of the size of the input, "" for none
+func (ip *ImageProcessor) Carve(protectPath, removePath string) error {
+	var masks interpolator.Masks
+
+	for _, m := range []struct {
+		path string
+		img  *image.Image
+	}{{protectPath, &masks.Protect}, {removePath, &masks.Remove}} {
+		if m.path == "" {
+			continue
+		}
+
+		r, err := os.Open(m.path)
+		if err != nil {
+			return err
+		}
+		i, _, err := image.Decode(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		*m.img = i
+	}
+
+	ip.method = "seamcarve"
+	ip.interpolator = interpolator.NewSeamCarver(ip.src, ip.w, ip.h, masks, ip.opts)
+
+	return nil
+}
+
+// Flip makes CreateImageFile mirror the resized image by op, see interpolator.Flip
+// calls add up, transposing operations swap the width and height of the output
+func (ip *ImageProcessor) Flip(op string) {
+	ip.flips = append(ip.flips, op)
+}
+
+// Rotate makes CreateImageFile rotate the resized image
+func (ip *ImageProcessor) Rotate(r interpolator.Rotation) {
+	ip.rotation = &r
+}
+
+// creates a filter of src, see Blur and BoxBlur
+type newFilter func(src *image.NRGBA, opts filter.Options) filter.Filter
+
+// Blur makes CreateImageFile blur the image with a gaussian of standard deviation sigma once it is resized, flipped and rotated
+// calls add up, blurred images have 8 bits per channel
+func (ip *ImageProcessor) Blur(sigma float64) {
+	ip.blurs = append(ip.blurs, func(src *image.NRGBA, opts filter.Options) filter.Filter {
+		return filter.NewGaussianBlur(src, sigma, opts)
+	})
+}
+
+// BoxBlur is the same as Blur, but averages the pixels within radius of each pixel passes times, see filter.BoxBlur
+func (ip *ImageProcessor) BoxBlur(radius, passes int) {
+	ip.blurs = append(ip.blurs, func(src *image.NRGBA, opts filter.Options) filter.Filter {
+		return filter.NewBoxBlur(src, radius, passes, opts)
+	})
+}
+
+// Sharpen makes CreateImageFile sharpen the image with an unsharp mask once it is resized, flipped and rotated
+// sharpened images have 8 bits per channel
+func (ip *ImageProcessor) Sharpen(s filter.Sharpening) {
+	ip.sharpening = &s
 }
 
 // readImageFile the input image and then convert it into *image.NRGBA
+// (*image.NRGBA64 if it has 16 bits per channel, so that no precision is lost)
+// grayscale (*image.Gray) and YCbCr (*image.YCbCr, most JPEGs) images are kept as they are,
+// the interpolators work on their planes directly
 // after that, set that into ip.src
 func (ip *ImageProcessor) readImageFile() error {
-	// read the image from file path
-	r, err := os.Open(ip.path)
-	if err != nil {
-		return err
+	// read the image from file path, or from stdin
+	var r io.Reader = os.Stdin
+	if ip.path != Stdio {
+		f, err := os.Open(ip.path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
 	}
-	defer r.Close()
 
 	var i image.Image
 	// decode in-memory image into image.Image interface
-	if ip.iExt == "jpeg" {
+	// stdin without an explicit format is recognized by its content
+	if ip.iExt == "" {
+		d, format, err := image.Decode(r)
+		if err != nil {
+			return err
+		}
+		if format != "jpeg" && format != "png" {
+			return fmt.Errorf("input image only available for jpg/jpeg and png, got %s", format)
+		}
+		i = d
+		ip.iExt = format
+
+	} else if ip.iExt == "jpeg" {
 		d, err := jpeg.Decode(r)
 		if err != nil {
 			return err
@@ -51,49 +151,117 @@ func (ip *ImageProcessor) readImageFile() error {
 		i = d
 	}
 
+	switch i.(type) {
+	case *image.Gray, *image.YCbCr:
+		ip.src = i
+		return nil
+	}
+
 	// get the size of the input image
 	iRect := i.Bounds()
 	iW, iH := iRect.Size().X, iRect.Size().Y
 
-	// convert image into more useful form *image.NRGBA
+	// convert image into more useful form *image.NRGBA(64)
 	// so that we can pass it to draw.Draw
-	rgba := image.NewNRGBA(image.Rect(0, 0, iW, iH))
-	draw.Draw(rgba, iRect, i, iRect.Min, draw.Src)
+	var dst draw.Image
+	switch i.ColorModel() {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model:
+		dst = image.NewNRGBA64(image.Rect(0, 0, iW, iH))
+	default:
+		dst = image.NewNRGBA(image.Rect(0, 0, iW, iH))
+	}
+	draw.Draw(dst, dst.Bounds(), i, iRect.Min, draw.Src)
 
-	ip.src = rgba
+	ip.src = dst
 
 	return nil
 }
 
 func (ip *ImageProcessor) CreateImageFile() error {
-	f, err := os.Create(ip.name)
+	// keeps 16 bits per channel for png, jpeg is always encoded with 8 bits
+	p, err := ip.interpolator.InterpolateImage(context.Background(), ip.concurrency)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	p := ip.interpolator.Interpolate(ip.concurrency)
+	for _, op := range ip.flips {
+		p = interpolator.Flip(p, op)
+	}
 
-	if ip.oExt == "jpeg" {
-		if err := jpeg.Encode(f, p, nil); err != nil {
+	if ip.rotation != nil {
+		p, err = interpolator.NewRotate(p, *ip.rotation, ip.method, ip.opts).InterpolateImage(context.Background(), ip.concurrency)
+		if err != nil {
 			return err
 		}
-	} else {
-		if err := png.Encode(f, p); err != nil {
+	}
+
+	fOpts := filter.Options{Workers: ip.opts.Workers, Pool: ip.opts.Pool, Partition: ip.opts.Partition}
+	for _, blur := range ip.blurs {
+		p, err = blur(interpolator.ToNRGBA(p), fOpts).ApplyContext(context.Background(), ip.concurrency)
+		if err != nil {
 			return err
 		}
 	}
+
+	if ip.sharpening != nil {
+		p, err = filter.NewUnsharpMask(interpolator.ToNRGBA(p), *ip.sharpening, fOpts).ApplyContext(context.Background(), ip.concurrency)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the file is only created once the image is ready, so that failures don't leave an empty one behind
+	if ip.name == Stdio {
+		return ip.encode(os.Stdout, p)
+	}
+	f, err := os.Create(ip.name)
+	if err != nil {
+		return err
+	}
+	if err := ip.encode(f, p); err != nil {
+		f.Close()
+		os.Remove(ip.name)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(ip.name)
+		return err
+	}
 	return nil
 }
 
+// encodes p into w in the output format
+func (ip *ImageProcessor) encode(w io.Writer, p image.Image) error {
+	if ip.oExt == "jpeg" {
+		return jpeg.Encode(w, p, nil)
+	}
+	return png.Encode(w, p)
+}
+
 func New(path string, w, h int, method string, concurrency bool, name string) *ImageProcessor {
+	return NewWithOptions(path, w, h, method, concurrency, name, interpolator.Options{})
+}
+
+// same as New, but passes opts on to the interpolator
+func NewWithOptions(path string, w, h int, method string, concurrency bool, name string, opts interpolator.Options) *ImageProcessor {
+	return NewWithFormats(path, "", w, h, method, concurrency, name, "", opts)
+}
+
+// same as NewWithOptions, but with the formats ("jpeg", "jpg" or "png") of the input and the output,
+// which are taken from the extensions of path and name when "" is passed
+// path and name can be Stdio, the input format is then recognized by the content of stdin,
+// and the output format is the input format, name defaults to Stdio when path is
+func NewWithFormats(path, iFormat string, w, h int, method string, concurrency bool, name, oFormat string, opts interpolator.Options) *ImageProcessor {
 	// check path
 	if path == "" {
 		log.Fatal("input image path is required")
 	}
 
-	// check input file extension
-	iExt := extCheck(path)
+	// check input format, or else input file extension
+	iExt := formatCheck(iFormat)
+	if iExt == "" && path != Stdio {
+		iExt = extCheck(path)
+	}
 
 	// set path, extension, and concurrency
 	ip := &ImageProcessor{
@@ -123,34 +291,110 @@ func New(path string, w, h int, method string, concurrency bool, name string) *I
 	ip.w = w
 	ip.h = h
 
-	// set name (output filename)
+	// set name (output filename), images read from stdin are written to stdout
+	if name == "" && path == Stdio {
+		name = Stdio
+	}
 	if name == "" {
-		name = method + "." + ip.iExt
+		ext := formatCheck(oFormat)
+		if ext == "" {
+			ext = ip.iExt
+		}
+		name = method + "." + ext
 	}
 	ip.name = name
 
-	// set extension of output file
-	oExt := extCheck(name)
+	// set extension of output file, stdout without an explicit format keeps the input format
+	oExt := formatCheck(oFormat)
+	if oExt == "" && name == Stdio {
+		oExt = ip.iExt
+	}
+	if oExt == "" {
+		oExt = extCheck(name)
+	}
 	ip.oExt = oExt
 
 	// set interpolator
-	ip.interpolator = interpolator.New(ip.src, ip.w, ip.h, method)
+	ip.method = method
+	ip.opts = opts
+	ip.interpolator = interpolator.NewImage(ip.src, ip.w, ip.h, method, opts)
 
 	return ip
 }
 
+// Validate reports an error when NewWithFormats would stop on path, the formats, w and h, without reading the input,
+// so that callers can check them before anything is read
+func Validate(path, iFormat string, w, h int, name, oFormat string) error {
+	if path == "" {
+		return errors.New("input image path is required")
+	}
+	if w < 0 || h < 0 || (w == 0 && h == 0) {
+		return fmt.Errorf("invalid size %dx%d, at least one dimension, w or h, is required and neither can be negative", w, h)
+	}
+
+	iExt, err := parseFormat(iFormat)
+	if err != nil {
+		return err
+	}
+	if iExt == "" && path != Stdio {
+		if _, err := parseExt(path); err != nil {
+			return err
+		}
+	}
+
+	oExt, err := parseFormat(oFormat)
+	if err != nil {
+		return err
+	}
+	if oExt == "" && name != "" && name != Stdio {
+		if _, err := parseExt(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// returns "jpeg" or "png" for a format flag, "" when it is omitted
+func formatCheck(s string) string {
+	f, err := parseFormat(s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return f
+}
+
+func parseFormat(s string) (string, error) {
+	switch s {
+	case "":
+		return "", nil
+	case "jpg", "jpeg":
+		return "jpeg", nil
+	case "png":
+		return "png", nil
+	}
+	return "", fmt.Errorf("invalid format %q, only jpg/jpeg and png are available", s)
+}
+
 func extCheck(s string) string {
-	re, err := regexp.Compile(`\.(jpe?g|png)$`)
+	extension, err := parseExt(s)
 	if err != nil {
 		log.Fatal(err)
 	}
-	matches := re.FindStringSubmatch(s)
+	return extension
+}
+
+var extPattern = regexp.MustCompile(`\.(jpe?g|png)$`)
+
+// returns "jpeg" or "png" for the extension of the file name s
+func parseExt(s string) (string, error) {
+	matches := extPattern.FindStringSubmatch(s)
 	if matches == nil {
-		log.Fatal("input image only available for jpg/jpeg and png")
+		return "", fmt.Errorf("%s: only jpg/jpeg and png images are available", s)
 	}
 	extension := matches[1]
 	if extension == "jpg" {
 		extension = "jpeg"
 	}
-	return extension
+	return extension, nil
 }
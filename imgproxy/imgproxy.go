@@ -0,0 +1,358 @@
+// Package imgproxy parses imgproxy-style processing URLs such as
+//
+//	/insecure/rs:fit:300:200/g:ce/plain/local:///path/to/image.jpg@png
+//
+// and maps them onto the options of this project, so that clients already
+// building imgproxy URLs can be pointed at it without changes.
+//
+// Supported options (full and short names):
+//   - resize, rs: %type:%width:%height:%enlarge (extend is not supported)
+//   - size, s: %width:%height:%enlarge
+//   - resizing_type, rt: fit | fill | force
+//   - width, w and height, h
+//   - enlarge, el
+//   - gravity, g: no | so | ea | we | noea | nowe | soea | sowe | ce
+//   - quality, q: 1-100, only meaningful for jpeg output
+//   - format, f, ext: jpg | jpeg | png, other formats such as webp fail with ErrFormat
+//   - resizing_algorithm, ra: nearest | linear | cubic
+//
+// As in imgproxy, the first segment is the signature, insecure or _ for
+// unsigned URLs, which is not verified. Unsigned URLs may also leave it out
+// when they start with an option or with plain. The source may be given
+// as plain/<url>[@<ext>] or as a base64url-encoded url, which may be split
+// into segments by /, followed by an optional .<ext>.
+package imgproxy
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Options holds everything parsed from an imgproxy-style URL
+type Options struct {
+	ResizingType string // "fit" | "fill" | "force", defaults to "fit"
+	Width        int    // 0 means "derive from the other dimension"
+	Height       int    // 0 means "derive from the other dimension"
+	Enlarge      bool   // allow the output to be larger than the source
+	Gravity      string // anchor used to crop when ResizingType is "fill", defaults to "ce"
+	Quality      int    // jpeg quality, 0 when omitted
+	Format       string // "jpeg" | "png", empty when the source format should be kept
+	Method       string // interpolation method name understood by interpolator.New
+	Source       string // source url, for example local:///path/to/image.jpg
+}
+
+// ErrFormat is returned by Parse for output formats that can't be written, such as webp
+var ErrFormat = errors.New("format not supported")
+
+// maps imgproxy resizing algorithms onto interpolation methods of this project
+var methods = map[string]string{
+	"nearest": "nearestneighbor",
+	"linear":  "bilinear",
+	"cubic":   "bicubic",
+}
+
+var gravities = map[string]bool{
+	"no": true, "so": true, "ea": true, "we": true,
+	"noea": true, "nowe": true, "soea": true, "sowe": true,
+	"ce": true,
+}
+
+// Parse reads the path of an imgproxy-style URL into Options
+func Parse(path string) (*Options, error) {
+	o := &Options{
+		ResizingType: "fit",
+		Gravity:      "ce",
+		Method:       "bicubic",
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) == 0 || segments[0] == "" {
+		return nil, fmt.Errorf("imgproxy: empty path")
+	}
+
+	// the signature is the first segment, unless it is an option or plain, which signatures never are,
+	// so that unsigned URLs can leave it out when they start with either
+	// it can't be told apart from a source segment by its content, unsigned URLs starting with their source need one
+	if segments[0] != "plain" && !strings.Contains(segments[0], ":") {
+		segments = segments[1:]
+	}
+
+	i := 0
+	for ; i < len(segments); i++ {
+		s := segments[i]
+		if s == "plain" || !strings.Contains(s, ":") {
+			break
+		}
+		if err := o.apply(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if i == len(segments) {
+		return nil, fmt.Errorf("imgproxy: source url is missing")
+	}
+
+	if err := o.parseSource(segments[i:]); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// applies a single "name:arg1:arg2..." option segment
+func (o *Options) apply(segment string) error {
+	parts := strings.Split(segment, ":")
+	name, args := parts[0], parts[1:]
+
+	var err error
+	switch name {
+	case "resize", "rs":
+		if err = o.setResizingType(arg(args, 0)); err != nil {
+			break
+		}
+		if err = o.setSize(args[min(1, len(args)):]); err != nil {
+			break
+		}
+		if len(args) > 4 && args[4] != "" {
+			if extend, _ := parseBool(args[4]); extend {
+				err = fmt.Errorf("extend is not supported")
+			}
+		}
+	case "size", "s":
+		err = o.setSize(args)
+	case "resizing_type", "rt":
+		err = o.setResizingType(arg(args, 0))
+	case "width", "w":
+		o.Width, err = parseDimension(arg(args, 0))
+	case "height", "h":
+		o.Height, err = parseDimension(arg(args, 0))
+	case "enlarge", "el":
+		o.Enlarge, err = parseBool(arg(args, 0))
+	case "gravity", "g":
+		g := arg(args, 0)
+		if !gravities[g] {
+			err = fmt.Errorf("unsupported gravity %q", g)
+			break
+		}
+		o.Gravity = g
+	case "quality", "q":
+		o.Quality, err = strconv.Atoi(arg(args, 0))
+		if err == nil && (o.Quality < 0 || o.Quality > 100) {
+			err = fmt.Errorf("quality must be between 0 and 100")
+		}
+	case "format", "f", "ext":
+		o.Format, err = parseFormat(arg(args, 0))
+	case "resizing_algorithm", "ra":
+		m, ok := methods[arg(args, 0)]
+		if !ok {
+			err = fmt.Errorf("unsupported resizing algorithm %q", arg(args, 0))
+			break
+		}
+		o.Method = m
+	default:
+		err = fmt.Errorf("unsupported option")
+	}
+
+	if err != nil {
+		return fmt.Errorf("imgproxy: %q: %w", segment, err)
+	}
+	return nil
+}
+
+// parses the %width:%height:%enlarge arguments shared by resize and size
+func (o *Options) setSize(args []string) error {
+	var err error
+	if v := arg(args, 0); v != "" {
+		if o.Width, err = parseDimension(v); err != nil {
+			return err
+		}
+	}
+	if v := arg(args, 1); v != "" {
+		if o.Height, err = parseDimension(v); err != nil {
+			return err
+		}
+	}
+	if v := arg(args, 2); v != "" {
+		if o.Enlarge, err = parseBool(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *Options) setResizingType(t string) error {
+	switch t {
+	case "":
+	case "fit", "fill", "force":
+		o.ResizingType = t
+	default:
+		return fmt.Errorf("unsupported resizing type %q", t)
+	}
+	return nil
+}
+
+// reads the source url and the optional output extension
+func (o *Options) parseSource(segments []string) error {
+	var src, ext string
+
+	if segments[0] == "plain" {
+		src = strings.Join(segments[1:], "/")
+		if at := strings.LastIndex(src, "@"); at >= 0 {
+			src, ext = src[:at], src[at+1:]
+		}
+	} else {
+		encoded := strings.Join(segments, "")
+		if dot := strings.LastIndex(encoded, "."); dot >= 0 {
+			encoded, ext = encoded[:dot], encoded[dot+1:]
+		}
+		decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+		if err != nil {
+			return fmt.Errorf("imgproxy: source url is not valid base64: %w", err)
+		}
+		src = string(decoded)
+	}
+
+	if src == "" {
+		return fmt.Errorf("imgproxy: source url is missing")
+	}
+	o.Source = src
+
+	if ext != "" {
+		f, err := parseFormat(ext)
+		if err != nil {
+			return fmt.Errorf("imgproxy: %w", err)
+		}
+		o.Format = f
+	}
+
+	return nil
+}
+
+// Path returns the file system path of a local:// source
+func (o *Options) Path() (string, error) {
+	p, ok := strings.CutPrefix(o.Source, "local://")
+	if !ok {
+		return "", fmt.Errorf("imgproxy: only local:// sources are supported, got %q", o.Source)
+	}
+	return p, nil
+}
+
+// Plan resolves the options against the size of the source image
+// crop: the part of the source that should be resized, the whole image unless ResizingType is "fill"
+// w, h: size of the output image
+func (o *Options) Plan(srcW, srcH int) (crop image.Rectangle, w, h int) {
+	crop = image.Rect(0, 0, srcW, srcH)
+	sW, sH := float64(srcW), float64(srcH)
+
+	switch o.ResizingType {
+	case "force":
+		w, h = o.Width, o.Height
+		if w == 0 {
+			w = srcW
+		}
+		if h == 0 {
+			h = srcH
+		}
+		if !o.Enlarge {
+			w, h = min(w, srcW), min(h, srcH)
+		}
+		return crop, w, h
+	case "fill":
+		if o.Width != 0 && o.Height != 0 {
+			scale := math.Max(float64(o.Width)/sW, float64(o.Height)/sH)
+			w, h = o.Width, o.Height
+			if !o.Enlarge && scale > 1 {
+				scale = 1
+				w, h = min(w, srcW), min(h, srcH)
+			}
+			// size of the source area that turns into the output after scaling
+			cW := min(srcW, int(math.Round(float64(w)/scale)))
+			cH := min(srcH, int(math.Round(float64(h)/scale)))
+			return o.gravityRect(srcW, srcH, cW, cH), w, h
+		}
+		// with a single dimension there is nothing to crop, fill behaves like fit
+	}
+
+	// fit
+	var scale float64
+	switch {
+	case o.Width == 0 && o.Height == 0:
+		scale = 1
+	case o.Width == 0:
+		scale = float64(o.Height) / sH
+	case o.Height == 0:
+		scale = float64(o.Width) / sW
+	default:
+		scale = math.Min(float64(o.Width)/sW, float64(o.Height)/sH)
+	}
+	if !o.Enlarge && scale > 1 {
+		scale = 1
+	}
+
+	w = max(1, int(math.Round(sW*scale)))
+	h = max(1, int(math.Round(sH*scale)))
+
+	return crop, w, h
+}
+
+// places a cW x cH rectangle inside the source according to the gravity
+func (o *Options) gravityRect(srcW, srcH, cW, cH int) image.Rectangle {
+	x := (srcW - cW) / 2
+	y := (srcH - cH) / 2
+
+	if strings.HasPrefix(o.Gravity, "no") {
+		y = 0
+	} else if strings.HasPrefix(o.Gravity, "so") {
+		y = srcH - cH
+	}
+
+	if strings.HasSuffix(o.Gravity, "we") {
+		x = 0
+	} else if strings.HasSuffix(o.Gravity, "ea") {
+		x = srcW - cW
+	}
+
+	return image.Rect(x, y, x+cW, y+cH)
+}
+
+// helpers
+func arg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+func parseDimension(s string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid dimension %q", s)
+	}
+	return v, nil
+}
+
+func parseBool(s string) (bool, error) {
+	switch s {
+	case "1", "t", "true":
+		return true, nil
+	case "0", "f", "false", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", s)
+}
+
+// only jpeg(jpg) and png are available, same as imageprocessor
+func parseFormat(s string) (string, error) {
+	switch s {
+	case "jpg", "jpeg":
+		return "jpeg", nil
+	case "png":
+		return "png", nil
+	}
+	return "", fmt.Errorf("%w: %q, only jpg/jpeg and png can be written", ErrFormat, s)
+}
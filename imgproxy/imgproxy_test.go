@@ -0,0 +1,156 @@
+package imgproxy
+
+import (
+	"encoding/base64"
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	o, err := Parse("/insecure/rs:fit:300:200/g:ce/ra:linear/q:80/plain/local:///tmp/image.jpg@png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Options{
+		ResizingType: "fit",
+		Width:        300,
+		Height:       200,
+		Gravity:      "ce",
+		Quality:      80,
+		Format:       "png",
+		Method:       "bilinear",
+		Source:       "local:///tmp/image.jpg",
+	}
+	if *o != expected {
+		t.Errorf("expected options to be:\n%+v\nbut instead got:\n%+v\n", expected, *o)
+	}
+
+	p, err := o.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != "/tmp/image.jpg" {
+		t.Errorf("expected path to be /tmp/image.jpg but instead got %s", p)
+	}
+}
+
+func TestParseSignatureAndBase64Source(t *testing.T) {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte("local:///tmp/image.png"))
+
+	o, err := Parse("/insecure/w:100/h:50/rt:force/el:1/" + encoded + ".jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o.Source != "local:///tmp/image.png" || o.Format != "jpeg" || o.Width != 100 || o.Height != 50 || o.ResizingType != "force" || !o.Enlarge {
+		t.Errorf("unexpected options %+v", *o)
+	}
+}
+
+func TestParseUnsignedSplitBase64Source(t *testing.T) {
+	// base64 sources may be split into segments, the first of which looks like a signature
+	encoded := base64.RawURLEncoding.EncodeToString([]byte("local:///tmp/image.png"))
+	split := encoded[:8] + "/" + encoded[8:]
+
+	for _, signature := range []string{"insecure", "_"} {
+		o, err := Parse("/" + signature + "/" + split + ".jpg")
+		if err != nil {
+			t.Errorf("%s: %v", signature, err)
+			continue
+		}
+		if o.Source != "local:///tmp/image.png" || o.Format != "jpeg" {
+			t.Errorf("%s: expected source local:///tmp/image.png written as jpeg but instead got %+v", signature, *o)
+		}
+	}
+
+	// without a signature the first part of the source is taken for it
+	if o, err := Parse("/" + split + ".jpg"); err == nil && o.Source == "local:///tmp/image.png" {
+		t.Error("expected the first segment to be taken for the signature but instead it was read as the source")
+	}
+}
+
+func TestParseUnsigned(t *testing.T) {
+	// unsigned URLs may start with an option or with plain
+	for path, source := range map[string]string{
+		"/rs:fit:300:200/g:ce/plain/local:///path@png":                            "local:///path",
+		"/plain/local:///path":                                                    "local:///path",
+		"/w:100/" + base64.RawURLEncoding.EncodeToString([]byte("local:///path")): "local:///path",
+	} {
+		o, err := Parse(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if o.Source != source {
+			t.Errorf("%s: expected source %s but instead got %s", path, source, o.Source)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, path := range []string{
+		"/rs:fit:300:200/g:ce/plain/local:///path@webp",
+		"/_/f:avif/plain/local:///path",
+		"/_/" + base64.RawURLEncoding.EncodeToString([]byte("local:///path")) + ".gif",
+	} {
+		if _, err := Parse(path); !errors.Is(err, ErrFormat) {
+			t.Errorf("%s: expected ErrFormat but instead got %v", path, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, path := range []string{
+		"",
+		"/insecure",                // no source
+		"/insecure/rs:fit:300:200", // no source
+		"/_/rs:auto:300:200/plain/local:///a.jpg",  // unsupported resizing type
+		"/_/g:sm/plain/local:///a.jpg",             // unsupported gravity
+		"/_/ra:lanczos3/plain/local:///a.jpg",      // unsupported algorithm
+		"/_/bl:10/plain/local:///a.jpg",            // unsupported option
+		"/_/w:-1/plain/local:///a.jpg",             // invalid dimension
+		"/_/q:101/plain/local:///a.jpg",            // invalid quality
+		"/_/rs:fit:10:10:0:1/plain/local:///a.jpg", // extend
+	} {
+		if _, err := Parse(path); err == nil {
+			t.Errorf("expected an error for %q", path)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		path string
+		crop image.Rectangle
+		w, h int
+	}{
+		// fit keeps the ratio inside the box
+		{"/_/rs:fit:300:300/plain/local:///a.jpg", image.Rect(0, 0, 1000, 500), 300, 150},
+		// a single dimension keeps the ratio
+		{"/_/h:100/plain/local:///a.jpg", image.Rect(0, 0, 1000, 500), 200, 100},
+		// no enlargement by default
+		{"/_/rs:fit:3000:3000/plain/local:///a.jpg", image.Rect(0, 0, 1000, 500), 1000, 500},
+		{"/_/rs:fit:3000:3000:1/plain/local:///a.jpg", image.Rect(0, 0, 1000, 500), 3000, 1500},
+		// force ignores the ratio
+		{"/_/rs:force:300:300/plain/local:///a.jpg", image.Rect(0, 0, 1000, 500), 300, 300},
+		// fill crops the center by default
+		{"/_/rs:fill:300:300/plain/local:///a.jpg", image.Rect(250, 0, 750, 500), 300, 300},
+		// and follows the gravity
+		{"/_/rs:fill:300:300/g:we/plain/local:///a.jpg", image.Rect(0, 0, 500, 500), 300, 300},
+		{"/_/rs:fill:300:300/g:soea/plain/local:///a.jpg", image.Rect(500, 0, 1000, 500), 300, 300},
+	}
+
+	for _, tt := range tests {
+		o, err := Parse(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		crop, w, h := o.Plan(1000, 500)
+		if crop != tt.crop || w != tt.w || h != tt.h {
+			t.Errorf("%s: expected %v %dx%d but instead got %v %dx%d", tt.path, tt.crop, tt.w, tt.h, crop, w, h)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"strings"
+
+	"gthub.com/obzva/image-resize/exif"
+)
+
+// what info prints about an image
+type imageInfo struct {
+	Path       string     `json:"path"`
+	Format     string     `json:"format"`
+	Width      int        `json:"width"`
+	Height     int        `json:"height"`
+	ColorModel string     `json:"color_model"`
+	Bytes      int64      `json:"bytes"`
+	Exif       *exif.Exif `json:"exif,omitempty"`
+	exifErr    error      // why the EXIF metadata of the image couldn't be read, nil without any or when it was
+}
+
+// prints the size, format, color model and EXIF summary of the images passed as arguments
+func runInfo(args []string) error {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "usage: image-resize info [flags] image...\n\nPrints the size, format, color model and EXIF summary (camera, date, orientation and exposure) of images.\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	jsonPtr := fs.Bool("json", false, "print one JSON object per image instead of text, defaults to false when omitted")
+
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("at least one image is required")
+	}
+
+	for _, path := range fs.Args() {
+		info, err := readInfo(path)
+		if err != nil {
+			return err
+		}
+		// broken metadata doesn't keep the rest from being printed
+		if info.exifErr != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s: %v\n", path, info.exifErr)
+		}
+
+		if *jsonPtr {
+			b, err := json.Marshal(info)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+			continue
+		}
+
+		fmt.Println(info.Path)
+		fmt.Printf("  format:      %s\n", info.Format)
+		fmt.Printf("  size:        %dx%d\n", info.Width, info.Height)
+		fmt.Printf("  color model: %s\n", info.ColorModel)
+		fmt.Printf("  file size:   %d bytes\n", info.Bytes)
+
+		if info.exifErr != nil {
+			fmt.Println("  exif:        unreadable")
+			continue
+		}
+		if info.Exif == nil {
+			fmt.Println("  exif:        none")
+			continue
+		}
+		e := info.Exif
+		if camera := strings.TrimSpace(e.Make + " " + e.Model); camera != "" {
+			fmt.Printf("  camera:      %s\n", camera)
+		}
+		if e.DateTime != "" {
+			fmt.Printf("  date:        %s\n", e.DateTime)
+		}
+		if e.Orientation != 0 {
+			fmt.Printf("  orientation: %d\n", e.Orientation)
+		}
+		var exposure []string
+		if e.Exposure != "" {
+			exposure = append(exposure, e.Exposure+" s")
+		}
+		if e.FNumber != 0 {
+			exposure = append(exposure, fmt.Sprintf("f/%g", e.FNumber))
+		}
+		if e.ISO != 0 {
+			exposure = append(exposure, fmt.Sprintf("ISO %d", e.ISO))
+		}
+		if e.FocalLength != 0 {
+			exposure = append(exposure, fmt.Sprintf("%g mm", e.FocalLength))
+		}
+		if len(exposure) > 0 {
+			fmt.Printf("  exposure:    %s\n", strings.Join(exposure, ", "))
+		}
+		if e.Software != "" {
+			fmt.Printf("  software:    %s\n", e.Software)
+		}
+	}
+
+	return nil
+}
+
+// decodes the header of the image at path and its EXIF metadata, without decoding any pixel
+func readInfo(path string) (*imageInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	config, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	info := &imageInfo{
+		Path:       path,
+		Format:     format,
+		Width:      config.Width,
+		Height:     config.Height,
+		ColorModel: describeModel(config.ColorModel),
+		Bytes:      stat.Size(),
+	}
+
+	// the subsampling of YCbCr images is not part of their color model, but of their frame header
+	if config.ColorModel == color.YCbCrModel {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if r := jpegSubsampling(f); r != "" {
+			info.ColorModel += " " + r
+		}
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	e, err := exif.Decode(f)
+	switch {
+	case err == nil:
+		info.Exif = e
+	case !errors.Is(err, exif.ErrNotFound):
+		info.exifErr = err
+	}
+
+	return info, nil
+}
+
+// describes how the pixels of images of model m are stored
+func describeModel(m color.Model) string {
+	// palettes are slices, which can't be compared to the other models
+	if p, ok := m.(color.Palette); ok {
+		return fmt.Sprintf("Paletted, %d colors", len(p))
+	}
+
+	switch m {
+	case color.YCbCrModel:
+		return "YCbCr"
+	case color.GrayModel:
+		return "Gray, 8 bits"
+	case color.Gray16Model:
+		return "Gray, 16 bits"
+	case color.NRGBAModel:
+		return "NRGBA, 8 bits per channel"
+	case color.NRGBA64Model:
+		return "NRGBA, 16 bits per channel"
+	case color.RGBAModel:
+		return "RGBA, 8 bits per channel, premultiplied alpha"
+	case color.RGBA64Model:
+		return "RGBA, 16 bits per channel, premultiplied alpha"
+	case color.CMYKModel:
+		return "CMYK"
+	}
+	return fmt.Sprintf("%T", m)
+}
+
+// returns the chroma subsampling of the jpeg read from r, such as "4:2:0", from the sampling factors of its frame header
+// "" is returned when there is no such header, or when its factors are not the ones of a subsampling ratio of image.YCbCr
+func jpegSubsampling(r io.Reader) string {
+	br := bufio.NewReader(r)
+
+	var soi [2]byte
+	if _, err := io.ReadFull(br, soi[:]); err != nil || soi != [2]byte{0xff, 0xd8} {
+		return ""
+	}
+
+	for {
+		// a marker is 0xff, possibly padded with more of them, followed by its code
+		c, err := br.ReadByte()
+		if err != nil || c != 0xff {
+			return ""
+		}
+		for c == 0xff {
+			if c, err = br.ReadByte(); err != nil {
+				return ""
+			}
+		}
+		// restart markers and TEM have no length, and the image data starts after a start of scan
+		if c == 0x01 || (c >= 0xd0 && c <= 0xd7) {
+			continue
+		}
+		if c == 0xda || c == 0xd9 {
+			return ""
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(br, length[:]); err != nil {
+			return ""
+		}
+		n := int(length[0])<<8 | int(length[1]) - 2
+		if n < 0 {
+			return ""
+		}
+
+		// start of frame markers, between which DHT, JPG and DAC are not ones
+		if c < 0xc0 || c > 0xcf || c == 0xc4 || c == 0xc8 || c == 0xcc {
+			if _, err := br.Discard(n); err != nil {
+				return ""
+			}
+			continue
+		}
+
+		// precision, height, width and the number of components, followed by the id, the sampling factors
+		// and the quantization table of each component
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(br, frame); err != nil || n < 15 || frame[5] != 3 {
+			return ""
+		}
+		// the chroma components are never sampled more than once per block in image.YCbCr
+		if frame[10] != 0x11 || frame[13] != 0x11 {
+			return ""
+		}
+		switch frame[7] {
+		case 0x11:
+			return "4:4:4"
+		case 0x12:
+			return "4:4:0"
+		case 0x21:
+			return "4:2:2"
+		case 0x22:
+			return "4:2:0"
+		case 0x41:
+			return "4:1:1"
+		case 0x42:
+			return "4:1:0"
+		}
+		return ""
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writes the encoded image data into name in a temporary directory, and returns its path
+func writeTemp(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadInfo(t *testing.T) {
+	rgba := image.NewNRGBA(image.Rect(0, 0, 12, 7))
+	for i := range rgba.Pix {
+		rgba.Pix[i] = uint8(i * 31)
+	}
+
+	encode := func(img image.Image, encoder func(io.Writer, image.Image) error) []byte {
+		var b bytes.Buffer
+		if err := encoder(&b, img); err != nil {
+			t.Fatal(err)
+		}
+		return b.Bytes()
+	}
+	toJPEG := func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, nil) }
+
+	for _, c := range []struct {
+		name, format, model string
+		data                []byte
+	}{
+		{"rgba.png", "png", "NRGBA, 8 bits per channel", encode(rgba, png.Encode)},
+		{"gray.png", "png", "Gray, 16 bits", encode(image.NewGray16(image.Rect(0, 0, 12, 7)), png.Encode)},
+		{"paletted.png", "png", "Paletted, 2 colors", encode(image.NewPaletted(image.Rect(0, 0, 12, 7), color.Palette{color.Black, color.White}), png.Encode)},
+		{"rgba.jpg", "jpeg", "YCbCr 4:2:0", encode(rgba, toJPEG)},
+		{"gray.jpg", "jpeg", "Gray, 8 bits", encode(image.NewGray(image.Rect(0, 0, 12, 7)), toJPEG)},
+	} {
+		info, err := readInfo(writeTemp(t, c.name, c.data))
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if info.Format != c.format || info.Width != 12 || info.Height != 7 || info.ColorModel != c.model {
+			t.Errorf("%s: expected %s 12x7 %q but instead got %s %dx%d %q", c.name, c.format, c.model, info.Format, info.Width, info.Height, info.ColorModel)
+		}
+		if info.Exif != nil || info.exifErr != nil {
+			t.Errorf("%s: expected no EXIF metadata but instead got %+v and %v", c.name, info.Exif, info.exifErr)
+		}
+	}
+}
+
+func TestReadInfoBrokenExif(t *testing.T) {
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 12, 7)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// APP1 right after the start of image, with a TIFF header cut short
+	payload := []byte("Exif\x00\x00II*\x00")
+	segment := append([]byte{0xff, 0xe1}, binary.BigEndian.AppendUint16(nil, uint16(len(payload)+2))...)
+	data := append(append(append([]byte{}, img.Bytes()[:2]...), append(segment, payload...)...), img.Bytes()[2:]...)
+
+	// the rest of the info is still read
+	info, err := readInfo(writeTemp(t, "broken.jpg", data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.exifErr == nil {
+		t.Error("expected an EXIF error but instead got nil")
+	}
+	if info.Width != 12 || info.Height != 7 || info.ColorModel != "Gray, 8 bits" {
+		t.Errorf("expected 12x7 %q but instead got %dx%d %q", "Gray, 8 bits", info.Width, info.Height, info.ColorModel)
+	}
+}
@@ -0,0 +1,85 @@
+package interpolator
+
+import (
+	"math"
+	"sync"
+)
+
+// number of entries of the linear light to sRGB lookup table of 8-bit outputs
+// 16 bits keep every 8-bit value round-tripping through linear light unchanged
+const linearLevels = 1 << 16
+
+// sRGB-encoded 8-bit value -> linear light value, both scaled to [0, 255]
+var toLinear8 = sync.OnceValue(func() *[256]float64 {
+	var lut [256]float64
+	for i := range lut {
+		lut[i] = 255 * srgbToLinear(float64(i)/255)
+	}
+	return &lut
+})
+
+// sRGB-encoded 16-bit value -> linear light value, both scaled to [0, 65535]
+var toLinear16 = sync.OnceValue(func() *[65536]float64 {
+	var lut [65536]float64
+	for i := range lut {
+		lut[i] = 65535 * srgbToLinear(float64(i)/65535)
+	}
+	return &lut
+})
+
+// linear light value quantized to [0, linearLevels) -> sRGB-encoded 16-bit value
+var fromLinear = sync.OnceValue(func() *[linearLevels]uint16 {
+	var lut [linearLevels]uint16
+	for i := range lut {
+		lut[i] = uint16(math.Round(65535 * linearToSRGB(float64(i)/(linearLevels-1))))
+	}
+	return &lut
+})
+
+// https://en.wikipedia.org/wiki/SRGB#Transformation
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// converts a linear light value in [0, max] into an sRGB-encoded 16-bit value
+// 16-bit outputs are computed directly, as the lookup table is too coarse for their darkest tones
+func encodeSRGB(v, max float64) uint16 {
+	l := math.Min(math.Max(v/max, 0), 1)
+	if max == 65535 {
+		return uint16(math.Round(65535 * linearToSRGB(l)))
+	}
+	return fromLinear()[int(math.Round(l*(linearLevels-1)))]
+}
+
+// premultiplies R, G and B of v by its alpha, all of them in [0, max]
+// so that the color of (almost) transparent pixels doesn't bleed into their neighbors
+func premultiply(v [4]float64, max float64) [4]float64 {
+	if v[3] != max {
+		a := v[3] / max
+		v[0], v[1], v[2] = v[0]*a, v[1]*a, v[2]*a
+	}
+	return v
+}
+
+// reverse of premultiply, alpha is clamped to [0, max] first
+func unpremultiply(v [4]float64, max float64) [4]float64 {
+	a := math.Min(math.Max(v[3], 0), max)
+	if a == 0 {
+		return [4]float64{}
+	}
+	if a != max {
+		v[0], v[1], v[2] = v[0]*max/a, v[1]*max/a, v[2]*max/a
+	}
+	v[3] = a
+	return v
+}
@@ -0,0 +1,96 @@
+package interpolator
+
+import (
+	"context"
+	"image"
+	"math"
+)
+
+// B and C parameters of the named cubic filters, see Cubic
+var cubicPresets = map[string][2]float64{
+	"catmullrom": {0, 0.5},         // sharp, rings a little, same curve as bicubic
+	"mitchell":   {1. / 3, 1. / 3}, // balances ringing and blur
+	"bspline":    {1, 0},           // smooth, no ringing, blurs
+	"hermite":    {0, 0},           // no ringing, sharper than bspline
+}
+
+// Cubic interpolates with the cubic filter family of Mitchell and Netravali, tuned by the parameters B and C
+// larger B blurs more, larger C rings more
+// for more detail, please refer to https://en.wikipedia.org/wiki/Mitchell%E2%80%93Netravali_filters
+// the method of the interpolation is the name of the preset, or "cubic"
+type Cubic struct {
+	interpolation
+	input, output raster
+	transform     Transform // maps output coordinates onto input coordinates
+	b, c          float64
+}
+
+// weight of a point at distance x from the sampled point
+func (cu *Cubic) kernel(x float64) float64 {
+	b, c := cu.b, cu.c
+
+	x = math.Abs(x)
+	x2 := x * x
+	x3 := x2 * x
+
+	if x < 1 {
+		return ((12-9*b-6*c)*x3 + (-18+12*b+6*c)*x2 + (6 - 2*b)) / 6
+	} else if x < 2 {
+		return ((-b-6*c)*x3 + (6*b+30*c)*x2 + (-12*b-48*c)*x + (8*b + 24*c)) / 6
+	}
+	return 0
+}
+
+// calculates the color values at (tX, tY) of the input space, x first y later
+// integer coordinates are the centers of input pixels, points outside of the input are read by the edge mode
+func (cu *Cubic) sample(tX, tY float64) [4]float64 {
+	// number of color channels to interpolate
+	n := cu.input.channels()
+
+	floorX := math.Floor(tX)
+	fractionX := tX - floorX
+
+	intX := int(floorX)
+
+	floorY := math.Floor(tY)
+	fractionY := tY - floorY
+
+	intY := int(floorY)
+
+	// weights of the four points around tX and tY, at distance 1+fraction, fraction, 1-fraction and 2-fraction
+	var wX, wY [4]float64
+	for i := range 4 {
+		wX[i] = cu.kernel(float64(i-1) - fractionX)
+		wY[i] = cu.kernel(float64(i-1) - fractionY)
+	}
+
+	// the 4x4 points around (tX, tY) are read without edge handling when they all lie inside of the input
+	input := cu.input
+	if e, ok := input.(*edgeRaster); ok {
+		input = e.window(intX-1, intY-1, intX+2, intY+2)
+	}
+
+	var iC [4]float64
+
+	for i := range 4 {
+		// values of each color channel got from the filter on x-axis
+		var row [4]float64
+
+		for j := range 4 {
+			pRGBA := input.at(intX-1+j, intY-1+i)
+			for c := range n {
+				row[c] += wX[j] * pRGBA[c]
+			}
+		}
+
+		for c := range n {
+			iC[c] += wY[i] * row[c]
+		}
+	}
+
+	return iC
+}
+
+func (cu *Cubic) run(ctx context.Context, concurrency bool) (image.Image, error) {
+	return resample(ctx, concurrency, cu.opts, cu.output, cu.transform, cu)
+}
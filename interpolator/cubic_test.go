@@ -0,0 +1,108 @@
+package interpolator
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestCubicKernel(t *testing.T) {
+	for method, bc := range cubicPresets {
+		cu := &Cubic{b: bc[0], c: bc[1]}
+
+		// the weights of the four points around any sampled point sum up to 1
+		for _, fraction := range []float64{0, 0.1, 0.25, 0.5, 0.9} {
+			var sum float64
+			for i := range 4 {
+				sum += cu.kernel(float64(i-1) - fraction)
+			}
+			if math.Abs(sum-1) > 1e-9 {
+				t.Errorf("%s: expected the weights at %v to sum up to 1 but instead got %v", method, fraction, sum)
+			}
+		}
+	}
+
+	// filters without B pass through the input pixels, B-spline blurs them
+	tests := []struct {
+		method   string
+		expected float64
+	}{
+		{"catmullrom", 1},
+		{"hermite", 1},
+		{"mitchell", 8. / 9},
+		{"bspline", 2. / 3},
+	}
+	for _, tt := range tests {
+		bc := cubicPresets[tt.method]
+		cu := &Cubic{b: bc[0], c: bc[1]}
+		if actual := cu.kernel(0); math.Abs(actual-tt.expected) > 1e-9 {
+			t.Errorf("%s: expected the weight at 0 to be %v but instead got %v", tt.method, tt.expected, actual)
+		}
+	}
+}
+
+func TestCubic(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 16, 12))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i * 37)
+	}
+	for i := 3; i < len(src.Pix); i += 4 {
+		src.Pix[i] = 255
+	}
+
+	// catmullrom is the curve of bicubic, away from the border where bicubic reads the nearest pixel
+	// (the 4 outer pixels map within 1.5 input pixels of it)
+	expected := New(src, 37, 29, "bicubic").Interpolate(false)
+	actual := New(src, 37, 29, "catmullrom").Interpolate(false)
+	for y := 4; y < 29-4; y++ {
+		for x := 4; x < 37-4; x++ {
+			e, a := expected.NRGBAAt(x, y), actual.NRGBAAt(x, y)
+			if absDiff(e.R, a.R) > 1 || absDiff(e.G, a.G) > 1 || absDiff(e.B, a.B) > 1 || e.A != a.A {
+				t.Fatalf("catmullrom: expected %v at [%d, %d], same as bicubic, but instead got %v", e, x, y, a)
+			}
+		}
+	}
+
+	// presets are the cubic method with their B and C
+	expected = New(src, 37, 29, "mitchell").Interpolate(false)
+	actual = NewWithOptions(src, 37, 29, "cubic", Options{B: 1. / 3, C: 1. / 3}).Interpolate(false)
+	for i := range expected.Pix {
+		if expected.Pix[i] != actual.Pix[i] {
+			t.Fatalf("cubic: expected %d at index %d, same as mitchell, but instead got %d", expected.Pix[i], i, actual.Pix[i])
+		}
+	}
+}
+
+func TestCubicRinging(t *testing.T) {
+	// dark gray on the left, light gray on the right
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 1))
+	for x := range 8 {
+		v := uint8(64)
+		if x >= 4 {
+			v = 192
+		}
+		src.SetNRGBA(x, 0, color.NRGBA{v, v, v, 255})
+	}
+
+	// the lowest and highest values of the upscaled edge
+	extremes := func(method string) (uint8, uint8) {
+		output := New(src, 64, 1, method).Interpolate(false)
+		lo, hi := uint8(255), uint8(0)
+		for x := range 64 {
+			lo = min(lo, output.NRGBAAt(x, 0).R)
+			hi = max(hi, output.NRGBAAt(x, 0).R)
+		}
+		return lo, hi
+	}
+
+	// B-spline and Hermite stay inside of the input values, Catmull-Rom overshoots them
+	for _, method := range []string{"bspline", "hermite"} {
+		if lo, hi := extremes(method); lo < 64 || hi > 192 {
+			t.Errorf("%s: expected values between 64 and 192 but instead got %d to %d", method, lo, hi)
+		}
+	}
+	if lo, hi := extremes("catmullrom"); lo >= 64 || hi <= 192 {
+		t.Errorf("catmullrom: expected values past 64 and 192 but instead got %d to %d", lo, hi)
+	}
+}
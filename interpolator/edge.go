@@ -0,0 +1,131 @@
+package interpolator
+
+import (
+	"image"
+	"image/color"
+	"log"
+)
+
+// available edge modes, see Options.Edge
+const (
+	EdgeClamp    = "clamp"    // repeats the pixels on the border of the input, bicubic reads the nearest pixel near the border instead
+	EdgeReflect  = "reflect"  // mirrors the input at its border, for photos
+	EdgeWrap     = "wrap"     // repeats the whole input, for seamless textures
+	EdgeConstant = "constant" // Options.EdgeColor, transparent by default, for warps
+)
+
+// edgeRaster reads a raster at any integer coordinates, pixels outside of it are given by an edge mode
+// writes go to the raster as they are
+type edgeRaster struct {
+	raster
+	mode     string
+	constant [4]float64 // color values outside of the raster with EdgeConstant
+	w, h     int
+}
+
+// returns r reading pixels outside of its bounds as described by opts.Edge
+func withEdges(r raster, opts Options) raster {
+	mode := opts.Edge
+	switch mode {
+	case "":
+		mode = EdgeClamp
+	case EdgeClamp, EdgeReflect, EdgeWrap, EdgeConstant:
+	default:
+		log.Fatal("wrong edge mode passed")
+	}
+
+	e := &edgeRaster{raster: r, mode: mode, w: r.Bounds().Dx(), h: r.Bounds().Dy()}
+	if mode == EdgeConstant {
+		e.constant = valueOf(r, opts.EdgeColor)
+	}
+	return e
+}
+
+func (e *edgeRaster) at(x, y int) [4]float64 {
+	if x >= 0 && x < e.w && y >= 0 && y < e.h {
+		return e.raster.at(x, y)
+	}
+
+	if e.mode == EdgeConstant {
+		return e.constant
+	}
+	return e.raster.at(edgeIndex(x, e.w, e.mode), edgeIndex(y, e.h, e.mode))
+}
+
+// returns the edge mode r reads pixels outside of its bounds with, EdgeClamp for rasters without one
+func edgeMode(r raster) string {
+	if e, ok := r.(*edgeRaster); ok {
+		return e.mode
+	}
+	return EdgeClamp
+}
+
+// returns the raster to read the pixels from (x0, y0) to (x1, y1) with, both inclusive
+// that is the wrapped raster when they all lie inside of it, which skips the edge handling, and e otherwise
+func (e *edgeRaster) window(x0, y0, x1, y1 int) raster {
+	if x0 >= 0 && y0 >= 0 && x1 < e.w && y1 < e.h {
+		return e.raster
+	}
+	return e
+}
+
+// maps i onto [0, n) by mode, one of EdgeClamp, EdgeReflect and EdgeWrap
+func edgeIndex(i, n int, mode string) int {
+	switch mode {
+	case EdgeReflect:
+		// the border pixels are repeated once: ... 1 0 | 0 1 ... n-1 | n-1 n-2 ...
+		i %= 2 * n
+		if i < 0 {
+			i += 2 * n
+		}
+		if i >= n {
+			i = 2*n - 1 - i
+		}
+		return i
+	case EdgeWrap:
+		i %= n
+		if i < 0 {
+			i += n
+		}
+		return i
+	}
+
+	return max(0, min(i, n-1))
+}
+
+// returns c in the working space of r
+// nil is transparent, or black for rasters without alpha
+func valueOf(r raster, c color.Color) [4]float64 {
+	if r.channels() == 4 && c == nil {
+		return [4]float64{}
+	}
+	if c == nil {
+		c = color.Black
+	}
+
+	// a single pixel raster of the same kind reads c as r would read its own pixels
+	switch r := r.(type) {
+	case *edgeRaster:
+		return valueOf(r.raster, c)
+	case *nrgba64Raster:
+		img := image.NewNRGBA64(image.Rect(0, 0, 1, 1))
+		img.Set(0, 0, c)
+		return (&nrgba64Raster{img, r.linear}).at(0, 0)
+	case *planeRaster:
+		img := image.NewGray(image.Rect(0, 0, 1, 1))
+		img.Set(0, 0, c)
+		return newGrayRaster(img, r.linear).at(0, 0)
+	case *ycbcrRaster:
+		img := image.NewYCbCr(image.Rect(0, 0, 1, 1), image.YCbCrSubsampleRatio444)
+		yc := color.YCbCrModel.Convert(c).(color.YCbCr)
+		img.Y[0], img.Cb[0], img.Cr[0] = yc.Y, yc.Cb, yc.Cr
+		return (&ycbcrRaster{img}).at(0, 0)
+	case *nrgbaRaster:
+		img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+		img.Set(0, 0, c)
+		return (&nrgbaRaster{img, r.linear}).at(0, 0)
+	}
+
+	log.Fatal("unknown raster passed")
+	return [4]float64{}
+}
@@ -0,0 +1,162 @@
+package interpolator
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEdgeIndex(t *testing.T) {
+	// indexes -4 to 6 of a row of 3 pixels
+	tests := map[string][]int{
+		EdgeClamp:   {0, 0, 0, 0, 0, 1, 2, 2, 2, 2, 2},
+		EdgeReflect: {2, 2, 1, 0, 0, 1, 2, 2, 1, 0, 0},
+		EdgeWrap:    {2, 0, 1, 2, 0, 1, 2, 0, 1, 2, 0},
+	}
+
+	for mode, expected := range tests {
+		for i, e := range expected {
+			if actual := edgeIndex(i-4, 3, mode); actual != e {
+				t.Errorf("%s: expected index %d to map onto %d but instead got %d", mode, i-4, e, actual)
+			}
+		}
+	}
+}
+
+func TestEdges(t *testing.T) {
+	black := color.NRGBA{0, 0, 0, 255}
+	white := color.NRGBA{255, 255, 255, 255}
+
+	// black on the left, white on the right
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.SetNRGBA(0, 0, black)
+	src.SetNRGBA(1, 0, white)
+
+	// the first output pixel of an 8x upscale lies left of the center of the black pixel
+	tests := []struct {
+		edge      string
+		edgeColor color.Color
+		expected  color.NRGBA
+	}{
+		{EdgeClamp, nil, color.NRGBA{0, 0, 0, 255}},
+		{EdgeReflect, nil, color.NRGBA{0, 0, 0, 255}},
+		// blends with the white pixel on the other side
+		{EdgeWrap, nil, color.NRGBA{112, 112, 112, 255}},
+		// fades out, without darkening or lightening the color
+		{EdgeConstant, nil, color.NRGBA{0, 0, 0, 143}},
+		{EdgeConstant, color.NRGBA{255, 0, 0, 255}, color.NRGBA{112, 0, 0, 255}},
+	}
+
+	for _, tt := range tests {
+		actual := NewWithOptions(src, 16, 1, "bilinear", Options{Edge: tt.edge, EdgeColor: tt.edgeColor}).Interpolate(false)
+
+		if c := actual.NRGBAAt(0, 0); absDiff(c.R, tt.expected.R) > 1 || absDiff(c.G, tt.expected.G) > 1 || absDiff(c.B, tt.expected.B) > 1 || absDiff(c.A, tt.expected.A) > 1 {
+			t.Errorf("%s %v: expected %v at [0, 0] but instead got %v", tt.edge, tt.edgeColor, tt.expected, c)
+		}
+	}
+
+	// every mode reads the same pixels inside of the input
+	for _, method := range []string{"nearestneighbor", "bilinear", "bicubic"} {
+		big := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+		for i := range big.Pix {
+			big.Pix[i] = uint8(i * 7)
+		}
+
+		expected := NewWithOptions(big, 30, 30, method, Options{}).Interpolate(false)
+		for _, edge := range []string{EdgeReflect, EdgeWrap, EdgeConstant} {
+			actual := NewWithOptions(big, 30, 30, method, Options{Edge: edge}).Interpolate(false)
+
+			for y := 3; y < 27; y++ {
+				for x := 3; x < 27; x++ {
+					if actual.NRGBAAt(x, y) != expected.NRGBAAt(x, y) {
+						t.Fatalf("%s %s: expected %v at [%d, %d] but instead got %v", method, edge, expected.NRGBAAt(x, y), x, y, actual.NRGBAAt(x, y))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestBicubicClamp(t *testing.T) {
+	// green stripes one column wide, red growing downwards
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := range 4 {
+		for x := range 4 {
+			src.SetNRGBA(x, y, color.NRGBA{uint8(60 * y), uint8(255 * (x % 2)), 0, 255})
+		}
+	}
+
+	// the first output column lies less than a pixel away from the border, it reads the first column only
+	actual := NewWithOptions(src, 8, 8, "bicubic", Options{}).Interpolate(false)
+	for y := range 8 {
+		if c := actual.NRGBAAt(0, y); c.G != 0 {
+			t.Errorf("%s: expected G at [0, %d] to be 0 but instead got %v", EdgeClamp, y, c)
+		}
+	}
+	if c := actual.NRGBAAt(7, 7); c != src.NRGBAAt(3, 3) {
+		t.Errorf("%s: expected %v at the corner but instead got %v", EdgeClamp, src.NRGBAAt(3, 3), c)
+	}
+
+	// other modes interpolate up to the border
+	actual = NewWithOptions(src, 8, 8, "bicubic", Options{Edge: EdgeWrap}).Interpolate(false)
+	if c := actual.NRGBAAt(0, 0); c.G == 0 {
+		t.Errorf("%s: expected G at [0, 0] to blend the columns around it but instead got %v", EdgeWrap, c)
+	}
+}
+
+func TestEdgesYCbCr(t *testing.T) {
+	// mid gray, chroma subsampled
+	src := image.NewYCbCr(image.Rect(0, 0, 4, 4), image.YCbCrSubsampleRatio420)
+	for i := range src.Y {
+		src.Y[i] = 128
+	}
+	for i := range src.Cb {
+		src.Cb[i], src.Cr[i] = 128, 128
+	}
+
+	// images without alpha fade out into black, which is neutral in the chroma planes too
+	actual := NewWithOptions(ToNRGBA(src), 8, 8, "bilinear", Options{Edge: EdgeConstant}).Interpolate(false)
+	if c := actual.NRGBAAt(0, 0); c.A == 255 {
+		t.Errorf("nrgba: expected a transparent corner but instead got %v", c)
+	}
+
+	output := NewImage(src, 8, 8, "bilinear", Options{Edge: EdgeConstant}).Interpolate(false)
+	c := output.NRGBAAt(0, 0)
+	if c.R >= 128 || absDiff(c.R, c.G) > 2 || absDiff(c.R, c.B) > 2 {
+		t.Errorf("ycbcr: expected a dark gray corner but instead got %v", c)
+	}
+}
+
+func TestWarpEdges(t *testing.T) {
+	// 2x2 texture translated by half of its size
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	for i := range 4 {
+		src.SetNRGBA(i%2, i/2, color.NRGBA{uint8(i * 50), 0, 0, 255})
+	}
+
+	for _, edge := range []string{EdgeClamp, EdgeWrap, EdgeReflect} {
+		actual := NewWarp(src, 2, 2, Translate(1, 1), nil, "nearestneighbor", Options{Edge: edge}).Interpolate(false)
+
+		// only the top-left pixel maps inside of the input
+		if c := actual.NRGBAAt(0, 0); c.R != 150 || c.A != 255 {
+			t.Errorf("%s: expected the bottom right pixel of the input at [0, 0] but instead got %v", edge, c)
+		}
+
+		// wrapped and reflected textures cover the whole output
+		c := actual.NRGBAAt(1, 1)
+		switch edge {
+		case EdgeClamp:
+			if c.A != 0 {
+				t.Errorf("%s: expected the background at [1, 1] but instead got %v", edge, c)
+			}
+		case EdgeWrap:
+			if c.R != 0 || c.A != 255 {
+				t.Errorf("%s: expected the top left pixel of the input at [1, 1] but instead got %v", edge, c)
+			}
+		case EdgeReflect:
+			if c.R != 150 || c.A != 255 {
+				t.Errorf("%s: expected the mirrored bottom right pixel of the input at [1, 1] but instead got %v", edge, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package interpolator
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+
+	"gthub.com/obzva/image-resize/parallel"
+)
+
+// EdgeDirected follows edges only where the weights fit the pixels around a new pixel well:
+// their luma values must change, by a standard deviation in 8-bit units of at least ediMinDeviation,
+// and the squared errors of the fit must be at most ediMaxResidual of their variance
+// textures are fitted poorly, and interpolating them with such weights does worse than bicubic
+const (
+	ediMinDeviation = 8
+	ediMaxResidual  = 0.1
+)
+
+// EdgeDirected enlarges images by exactly 2x with new edge-directed interpolation (NEDI, Li and Orchard):
+// the weights of the four neighbors of each new pixel are fitted to how the pixels around it relate to their own
+// neighbors in the input, so that edges are followed instead of blurred across
+//
+// the new pixels are calculated on a grid through the centers of the input pixels and halfway between them,
+// first the ones between four diagonal neighbors, then the ones between two input and two new pixels
+// the output pixels don't lie on that grid, so the output is the bicubic one
+// corrected by how much the grid differs from bicubic interpolation, which is nothing away from edges
+type EdgeDirected struct {
+	interpolation
+	input, output raster
+	bicubic       *Bicubic
+	unit          float64 // scales color values to [0, 255]
+}
+
+// ediGrid holds the grid of one run of EdgeDirected, so that concurrent runs don't share it
+type ediGrid struct {
+	*EdgeDirected
+	grid   [][4]float64 // (2*input width-1) x (2*input height-1), (2x, 2y) are the input pixels
+	lumas  []float64    // luma of the grid pixels, see luma
+	gW, gH int
+}
+
+// initialize EdgeDirected, or Bicubic when output is not exactly twice the size of input
+func newEdgeDirected(input, output raster, opts Options) Interpolator {
+	bicubic := newBicubic(input, output, scaleOf(input, output), "bicubic", opts).(*Bicubic)
+	if output.Bounds().Size() != input.Bounds().Size().Mul(2) {
+		return bicubic
+	}
+
+	// alpha of white is the largest value of the channel type
+	unit := 255 / valueOf(input, color.White)[3]
+
+	ed := &EdgeDirected{input: input, output: output, bicubic: bicubic, unit: unit}
+	ed.interpolation = newInterpolation("nedi", input.Bounds(), output.Bounds(), opts, ed.run)
+	return ed
+}
+
+// reads the grid at (x, y), clamping coordinates outside of it
+func (ed *ediGrid) at(x, y int) [4]float64 {
+	x = max(0, min(x, ed.gW-1))
+	y = max(0, min(y, ed.gH-1))
+
+	return ed.grid[y*ed.gW+x]
+}
+
+// reads the luma of the grid at (x, y), clamping coordinates outside of it
+func (ed *ediGrid) lumaAt(x, y int) float64 {
+	x = max(0, min(x, ed.gW-1))
+	y = max(0, min(y, ed.gH-1))
+
+	return ed.lumas[y*ed.gW+x]
+}
+
+// returns the value the edge orientation is estimated from, luma in [0, 255]
+func (ed *EdgeDirected) luma(c [4]float64) float64 {
+	if ed.input.channels() != 4 {
+		// gray, or Y of YCbCr
+		return c[0] * ed.unit
+	}
+	return (0.299*c[0] + 0.587*c[1] + 0.114*c[2]) * ed.unit
+}
+
+// returns the bicubic interpolation at the grid pixel (x, y)
+func (ed *EdgeDirected) cubic(x, y int) [4]float64 {
+	return ed.bicubic.sample(float64(x)/2, float64(y)/2)
+}
+
+// calculates the grid pixel (x, y) from its four neighbors at offsets d, scaled by 1 for the pixel
+// and by 2 for the known pixels of the window around it whose own neighbors train the weights
+// window lists the offsets of the training pixels from (x, y)
+// areas without an edge to follow are interpolated bicubically
+func (ed *ediGrid) estimate(x, y int, d [4]image.Point, window []image.Point) [4]float64 {
+	n := ed.input.channels()
+
+	var p [4][4]float64
+	for k := range 4 {
+		p[k] = ed.at(x+d[k].X, y+d[k].Y)
+	}
+
+	var sum, sum2 float64
+	for _, w := range window {
+		v := ed.lumaAt(x+w.X, y+w.Y)
+		sum += v
+		sum2 += v * v
+	}
+
+	m := float64(len(window))
+	if sum2/m-(sum/m)*(sum/m) < ediMinDeviation*ediMinDeviation {
+		return ed.cubic(x, y)
+	}
+
+	// normal equations of the least squares fit of the training pixels onto their neighbors: r a = s
+	var r [4][4]float64
+	var s [4]float64
+
+	for _, w := range window {
+		tX, tY := x+w.X, y+w.Y
+		v := ed.lumaAt(tX, tY)
+
+		var l [4]float64
+		for k := range 4 {
+			l[k] = ed.lumaAt(tX+2*d[k].X, tY+2*d[k].Y)
+		}
+
+		for i := range 4 {
+			for j := range 4 {
+				r[i][j] += l[i] * l[j]
+			}
+			s[i] += l[i] * v
+		}
+	}
+
+	a, ok := solve4(r, s)
+	if !ok {
+		return ed.cubic(x, y)
+	}
+
+	// squared errors of the fit, |l a - v|² expanded as v·v - 2 a·s + a r a
+	residual := sum2
+	for i := range 4 {
+		residual -= 2 * a[i] * s[i]
+		for j := range 4 {
+			residual += a[i] * r[i][j] * a[j]
+		}
+	}
+	if residual > ediMaxResidual*(sum2-sum*sum/m) {
+		return ed.cubic(x, y)
+	}
+
+	// weights far from an interpolation are fitting noise
+	var total float64
+	for k := range 4 {
+		if math.Abs(a[k]) > 2 {
+			return ed.cubic(x, y)
+		}
+		total += a[k]
+	}
+	if math.Abs(total-1) > 0.25 {
+		return ed.cubic(x, y)
+	}
+
+	// the weights are normalized so that flat colors stay the same
+	var iC [4]float64
+	for k := range 4 {
+		for c := range n {
+			iC[c] += a[k] / total * p[k][c]
+		}
+	}
+
+	// keeps the color values inside of the neighbors so that edges don't ring
+	for c := range n {
+		lo := math.Min(math.Min(p[0][c], p[1][c]), math.Min(p[2][c], p[3][c]))
+		hi := math.Max(math.Max(p[0][c], p[1][c]), math.Max(p[2][c], p[3][c]))
+		iC[c] = math.Max(lo, math.Min(hi, iC[c]))
+	}
+
+	return iC
+}
+
+// offsets of the neighbors of the new pixels of the first and the second pass
+var (
+	ediDiagonal = [4]image.Point{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}}
+	ediAxial    = [4]image.Point{{0, -1}, {-1, 0}, {1, 0}, {0, 1}}
+)
+
+// offsets of the training pixels of the first and the second pass
+var ediDiagonalWindow, ediAxialWindow = func() (diagonal, axial []image.Point) {
+	// the 8x8 input pixels around a new pixel between four of them
+	for y := -7; y <= 7; y += 2 {
+		for x := -7; x <= 7; x += 2 {
+			diagonal = append(diagonal, image.Pt(x, y))
+		}
+	}
+
+	// the known pixels in a diamond around a new pixel between two input and two new pixels
+	for y := -4; y <= 4; y++ {
+		for x := -4; x <= 4; x++ {
+			if (x+y)%2 != 0 && abs(x)+abs(y) <= 5 {
+				axial = append(axial, image.Pt(x, y))
+			}
+		}
+	}
+
+	return diagonal, axial
+}()
+
+func abs(v int) int {
+	return max(v, -v)
+}
+
+// fills the grid pixel (x, y) in one pass
+// pass 0 copies the input pixels, pass 1 calculates the pixels between four of them, and pass 2 the rest
+// pass 3 turns the grid into its differences from bicubic interpolation
+func (ed *ediGrid) fill(pass int) func(x, y int) {
+	return func(x, y int) {
+		i := y*ed.gW + x
+
+		switch {
+		case pass == 0 && x%2 == 0 && y%2 == 0:
+			ed.grid[i] = ed.input.at(x/2, y/2)
+			ed.lumas[i] = ed.luma(ed.grid[i])
+		case pass == 1 && x%2 == 1 && y%2 == 1:
+			ed.grid[i] = ed.estimate(x, y, ediDiagonal, ediDiagonalWindow)
+			ed.lumas[i] = ed.luma(ed.grid[i])
+		case pass == 2 && (x+y)%2 == 1:
+			ed.grid[i] = ed.estimate(x, y, ediAxial, ediAxialWindow)
+		case pass == 3 && x%2 == 0 && y%2 == 0:
+			// bicubic interpolation passes through the input pixels
+			ed.grid[i] = [4]float64{}
+		case pass == 3:
+			c := ed.cubic(x, y)
+			for j := range c {
+				ed.grid[i][j] -= c[j]
+			}
+		}
+	}
+}
+
+// Catmull-Rom weights halfway between the two middle points
+var ediHalfway = [4]float64{-1. / 16, 9. / 16, 9. / 16, -1. / 16}
+
+// corrects the bicubic output pixel (x, y) by the differences of the grid, sampled halfway between its pixels where the output pixels lie
+func (ed *ediGrid) correct(x, y int) {
+	n := ed.input.channels()
+
+	tX, tY := ed.bicubic.transform.Map(float64(x), float64(y))
+	iC := ed.bicubic.sample(tX, tY)
+
+	// output pixel x lies at x/2 - 1/4 of the input, which is x - 1/2 of the grid
+	for j := range 4 {
+		for k := range 4 {
+			w := ediHalfway[j] * ediHalfway[k]
+			p := ed.at(x-2+k, y-2+j)
+			for c := range n {
+				iC[c] += w * p[c]
+			}
+		}
+	}
+
+	ed.output.set(x, y, iC)
+}
+
+func (ed *EdgeDirected) run(ctx context.Context, concurrency bool) (image.Image, error) {
+	g, err := ed.newGrid(ctx, concurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	oW := ed.output.Bounds().Dx()
+	oH := ed.output.Bounds().Dy()
+
+	if err := parallel.RunPixels(ctx, concurrency, ed.opts.parallel(), oW, oH, g.correct); err != nil {
+		return nil, err
+	}
+
+	return ed.output.image(), nil
+}
+
+// returns a new grid filled with the differences of the edge-directed interpolation from bicubic interpolation
+func (ed *EdgeDirected) newGrid(ctx context.Context, concurrency bool) (*ediGrid, error) {
+	gW := 2*ed.input.Bounds().Dx() - 1
+	gH := 2*ed.input.Bounds().Dy() - 1
+	g := &ediGrid{EdgeDirected: ed, grid: make([][4]float64, gW*gH), lumas: make([]float64, gW*gH), gW: gW, gH: gH}
+
+	// only the output rows are reported as progress
+	passOpts := ed.opts.parallel()
+	passOpts.Progress = nil
+
+	for pass := range 4 {
+		if err := parallel.RunPixels(ctx, concurrency, passOpts, gW, gH, g.fill(pass)); err != nil {
+			return nil, err
+		}
+	}
+
+	return g, nil
+}
+
+// solves the linear system a x = b by gaussian elimination with partial pivoting
+// reports false when a is (nearly) singular
+func solve4(a [4][4]float64, b [4]float64) ([4]float64, bool) {
+	for col := range 4 {
+		pivot := col
+		for row := col + 1; row < 4; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		// relative to the diagonal, the values are sums of squares
+		if math.Abs(a[pivot][col]) < 1e-9*(math.Abs(a[0][0])+math.Abs(a[1][1])+math.Abs(a[2][2])+math.Abs(a[3][3])) {
+			return [4]float64{}, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+
+		for row := col + 1; row < 4; row++ {
+			f := a[row][col] / a[col][col]
+			for k := col; k < 4; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+			b[row] -= f * b[col]
+		}
+	}
+
+	var x [4]float64
+	for row := 3; row >= 0; row-- {
+		v := b[row]
+		for k := row + 1; k < 4; k++ {
+			v -= a[row][k] * x[k]
+		}
+		x[row] = v / a[row][row]
+	}
+
+	return x, true
+}
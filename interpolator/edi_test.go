@@ -0,0 +1,146 @@
+package interpolator
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+
+	"gthub.com/obzva/image-resize/metrics"
+)
+
+// peak signal-to-noise ratio of b against a, in dB
+func psnr(t *testing.T, a, b *image.NRGBA) float64 {
+	v, err := metrics.PSNR(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// halves original and enlarges it back with bicubic and nedi, returning how close both get to original
+func edgeDirectedRoundTrip(t *testing.T, original *image.NRGBA) (bicubic, nedi float64) {
+	w, h := original.Bounds().Dx(), original.Bounds().Dy()
+
+	// bilinear averages 2x2 pixels when halving
+	small := New(original, w/2, h/2, "bilinear").Interpolate(true)
+
+	bicubic = psnr(t, original, New(small, w, h, "bicubic").Interpolate(true))
+	nedi = psnr(t, original, New(small, w, h, "nedi").Interpolate(true))
+
+	return bicubic, nedi
+}
+
+// returns a size x size gray image, contrast levels brighter where inside is true, anti-aliased with 4x4 samples per pixel
+// and with a little deterministic noise, so that no area is perfectly flat
+func edgeImage(size int, contrast float64, inside func(x, y float64) bool) *image.NRGBA {
+	src := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := range size {
+		for x := range size {
+			var cover float64
+			for sY := range 4 {
+				for sX := range 4 {
+					if inside(float64(x)+(float64(sX)+0.5)/4, float64(y)+(float64(sY)+0.5)/4) {
+						cover++
+					}
+				}
+			}
+			noise := 3 * math.Sin(float64(x*7919+y*104729))
+			v := uint8(math.Round(100 + contrast*cover/16 + noise))
+			src.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+	return src
+}
+
+func TestEdgeDirected(t *testing.T) {
+	const size = 128
+	shapes := []struct {
+		name   string
+		inside func(x, y float64) bool
+	}{
+		{"disk and line", func(x, y float64) bool {
+			x, y = x-size/2, y-size/2
+			return x*x+y*y < 40*40 || 0.3*x+y > 50
+		}},
+		{"diagonal stripes", func(x, y float64) bool {
+			return int(math.Floor((x+0.6*y)/11))%2 == 0
+		}},
+		{"star", func(x, y float64) bool {
+			return int(math.Floor(math.Atan2(y-size/2, x-size/2)/(math.Pi/8)+16))%2 == 0
+		}},
+	}
+
+	// curved and diagonal edges are followed instead of blurred, even faint ones
+	for _, shape := range shapes {
+		for _, contrast := range []float64{30, 120} {
+			bicubic, nedi := edgeDirectedRoundTrip(t, edgeImage(size, contrast, shape.inside))
+			if nedi < bicubic+0.3 {
+				t.Errorf("%s, contrast %v: expected nedi to be at least 0.3dB better than bicubic (%.2fdB) but instead got %.2fdB", shape.name, contrast, bicubic, nedi)
+			}
+		}
+	}
+
+	// other sizes are bicubic
+	src := edgeImage(size, 120, shapes[0].inside)
+	expected := New(src, 100, 256, "bicubic").Interpolate(false)
+	actual := New(src, 100, 256, "nedi").Interpolate(false)
+	for i := range expected.Pix {
+		if expected.Pix[i] != actual.Pix[i] {
+			t.Fatalf("expected %d at index %d, same as bicubic, but instead got %d", expected.Pix[i], i, actual.Pix[i])
+		}
+	}
+}
+
+func TestEdgeDirectedConcurrent(t *testing.T) {
+	src := edgeImage(64, 120, func(x, y float64) bool { return x+0.6*y > 50 })
+	ed := New(src, 128, 128, "nedi").(*EdgeDirected)
+	expected, err := ed.newGrid(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// runs share the interpolator but not their grids
+	var wg sync.WaitGroup
+	grids := make([]*ediGrid, 4)
+	for i := range grids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			grids[i], _ = ed.newGrid(context.Background(), true)
+		}()
+	}
+	wg.Wait()
+
+	for i, g := range grids {
+		if g == nil || !slices.Equal(g.grid, expected.grid) || !slices.Equal(g.lumas, expected.lumas) {
+			t.Errorf("expected run %d to fill the same grid as a single run but instead got a different one", i)
+		}
+	}
+}
+
+func TestEdgeDirectedQuality(t *testing.T) {
+	f, err := os.Open("../assets/images/test-image.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	img, err := jpeg.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the photo is mostly texture, which nedi leaves to bicubic, it must not do worse than bicubic on it
+	bicubic, nedi := edgeDirectedRoundTrip(t, ToNRGBA(img))
+	t.Logf("PSNR of test-image.jpg halved and enlarged back: bicubic %.3fdB, nedi %.3fdB", bicubic, nedi)
+
+	if nedi < bicubic-0.01 {
+		t.Errorf("expected nedi to be as good as bicubic (%.3fdB) but instead got %.3fdB", bicubic, nedi)
+	}
+}
@@ -0,0 +1,107 @@
+package interpolator
+
+import (
+	"image"
+	"log"
+)
+
+// available flip operations, see Flip
+const (
+	FlipHorizontal = "horizontal" // mirrors left and right
+	FlipVertical   = "vertical"   // mirrors top and bottom
+	Transpose      = "transpose"  // mirrors along the diagonal from the top-left corner, (x, y) becomes (y, x)
+	Transverse     = "transverse" // mirrors along the diagonal from the top-right corner
+)
+
+// returns src mirrored by op, one of FlipHorizontal, FlipVertical, Transpose and Transverse
+// pixels are copied as they are, so *image.NRGBA, *image.NRGBA64 and *image.Gray keep their type
+// anything else is converted into *image.NRGBA first
+// transposed images are h x w when src is w x h
+func Flip(src image.Image, op string) image.Image {
+	switch op {
+	case FlipHorizontal, FlipVertical, Transpose, Transverse:
+	default:
+		log.Fatal("wrong flip operation passed")
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if op == Transpose || op == Transverse {
+		w, h = h, w
+	}
+	r := image.Rect(0, 0, w, h)
+
+	switch src := src.(type) {
+	case *image.NRGBA64:
+		dst := image.NewNRGBA64(r)
+		flipPix(dst.Pix, src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], dst.Stride, src.Stride, 8, b.Dx(), b.Dy(), op)
+		return dst
+	case *image.Gray:
+		dst := image.NewGray(r)
+		flipPix(dst.Pix, src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], dst.Stride, src.Stride, 1, b.Dx(), b.Dy(), op)
+		return dst
+	}
+
+	nrgba := ToNRGBA(src)
+	dst := image.NewNRGBA(r)
+	flipPix(dst.Pix, nrgba.Pix[nrgba.PixOffset(nrgba.Rect.Min.X, nrgba.Rect.Min.Y):], dst.Stride, nrgba.Stride, 4, b.Dx(), b.Dy(), op)
+	return dst
+}
+
+// returns src with the EXIF orientation tag value orientation (1 to 8) undone, so that it is displayed upright
+// orientation 1 and unknown values return src as it is
+func Orient(src image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return Flip(src, FlipHorizontal)
+	case 3:
+		// 180 degrees
+		return Flip(Flip(src, FlipHorizontal), FlipVertical)
+	case 4:
+		return Flip(src, FlipVertical)
+	case 5:
+		return Flip(src, Transpose)
+	case 6:
+		// 90 degrees clockwise
+		return Flip(Flip(src, Transpose), FlipHorizontal)
+	case 7:
+		return Flip(src, Transverse)
+	case 8:
+		// 90 degrees counterclockwise
+		return Flip(Flip(src, Transpose), FlipVertical)
+	}
+	return src
+}
+
+// copies the w x h pixels of src, bpp bytes each, into dst mirrored by op
+// src starts at its top-left pixel, rows are strides apart in both buffers
+func flipPix(dst, src []uint8, dstStride, srcStride, bpp, w, h int, op string) {
+	rowLen := w * bpp
+
+	for y := range h {
+		row := src[y*srcStride : y*srcStride+rowLen]
+
+		switch op {
+		case FlipVertical:
+			// whole rows stay in order
+			copy(dst[(h-1-y)*dstStride:], row)
+		case FlipHorizontal:
+			d := dst[y*dstStride : y*dstStride+rowLen]
+			for x := 0; x < rowLen; x += bpp {
+				copy(d[rowLen-bpp-x:rowLen-x], row[x:x+bpp])
+			}
+		case Transpose:
+			// row y of src becomes column y of dst
+			for x := range w {
+				i := x*dstStride + y*bpp
+				copy(dst[i:i+bpp], row[x*bpp:x*bpp+bpp])
+			}
+		case Transverse:
+			// row y of src becomes column h-1-y of dst, read from the bottom
+			for x := range w {
+				i := (w-1-x)*dstStride + (h-1-y)*bpp
+				copy(dst[i:i+bpp], row[x*bpp:x*bpp+bpp])
+			}
+		}
+	}
+}
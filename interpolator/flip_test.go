@@ -0,0 +1,109 @@
+package interpolator
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFlip(t *testing.T) {
+	// 3x2 image with a different value in every pixel
+	// 1 2 3
+	// 4 5 6
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i := range 6 {
+		src.Set(i%3, i/3, color.NRGBA{uint8(i + 1), 0, 0, 255})
+	}
+
+	tests := []struct {
+		op       string
+		w, h     int
+		expected []uint8
+	}{
+		{FlipHorizontal, 3, 2, []uint8{3, 2, 1, 6, 5, 4}},
+		{FlipVertical, 3, 2, []uint8{4, 5, 6, 1, 2, 3}},
+		{Transpose, 2, 3, []uint8{1, 4, 2, 5, 3, 6}},
+		{Transverse, 2, 3, []uint8{6, 3, 5, 2, 4, 1}},
+	}
+
+	for _, tt := range tests {
+		// the same pixels in every supported type, and in a sub-image whose bounds don't start at (0, 0)
+		padded := image.NewNRGBA(image.Rect(0, 0, 5, 4))
+		for i := range 6 {
+			padded.Set(1+i%3, 1+i/3, src.At(i%3, i/3))
+		}
+		gray := image.NewGray(src.Bounds())
+		wide := image.NewNRGBA64(src.Bounds())
+		for i := range 6 {
+			gray.SetGray(i%3, i/3, color.Gray{uint8(i + 1)})
+			wide.SetNRGBA64(i%3, i/3, color.NRGBA64{uint16(i+1) * 257, 0, 0, 65535})
+		}
+
+		for name, img := range map[string]image.Image{
+			"nrgba":    src,
+			"subimage": padded.SubImage(image.Rect(1, 1, 4, 3)),
+			"gray":     gray,
+			"nrgba64":  wide,
+		} {
+			actual := Flip(img, tt.op)
+
+			if actual.Bounds() != image.Rect(0, 0, tt.w, tt.h) {
+				t.Fatalf("%s %s: expected %dx%d output but instead got %v", tt.op, name, tt.w, tt.h, actual.Bounds())
+			}
+			if name == "gray" {
+				if _, ok := actual.(*image.Gray); !ok {
+					t.Errorf("%s: expected *image.Gray to be kept but instead got %T", tt.op, actual)
+				}
+			}
+			if name == "nrgba64" {
+				if _, ok := actual.(*image.NRGBA64); !ok {
+					t.Errorf("%s: expected *image.NRGBA64 to be kept but instead got %T", tt.op, actual)
+				}
+			}
+
+			for i, e := range tt.expected {
+				r, _, _, _ := actual.At(i%tt.w, i/tt.w).RGBA()
+				if uint8(r>>8) != e {
+					t.Errorf("%s %s: expected R at [%d, %d] to be %d but instead got %d", tt.op, name, i%tt.w, i/tt.w, e, r>>8)
+				}
+			}
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i := range 6 {
+		src.Set(i%3, i/3, color.NRGBA{uint8(i + 1), 0, 0, 255})
+	}
+
+	// orientations that are plain rotations have to match the lossless quarter turns of NewRotate
+	for orientation, degrees := range map[int]float64{1: 0, 3: 180, 6: 90, 8: 270} {
+		expected := NewRotate(src, Rotation{Degrees: degrees, Expand: true}, "nearestneighbor", Options{}).Interpolate(false)
+		actual := ToNRGBA(Orient(src, orientation))
+
+		if actual.Bounds() != expected.Bounds() {
+			t.Fatalf("orientation %d: expected %v output but instead got %v", orientation, expected.Bounds(), actual.Bounds())
+		}
+		for i := range expected.Pix {
+			if actual.Pix[i] != expected.Pix[i] {
+				t.Errorf("orientation %d: expected %v but instead got %v", orientation, expected.Pix, actual.Pix)
+				break
+			}
+		}
+	}
+
+	// the mirrored ones undo themselves
+	for orientation := 2; orientation <= 8; orientation++ {
+		if orientation == 3 || orientation == 6 || orientation == 8 {
+			continue
+		}
+		actual := ToNRGBA(Orient(Orient(src, orientation), orientation))
+		for i := range src.Pix {
+			if actual.Pix[i] != src.Pix[i] {
+				t.Errorf("orientation %d: expected applying it twice to give %v but instead got %v", orientation, src.Pix, actual.Pix)
+				break
+			}
+		}
+	}
+}
@@ -1,13 +1,16 @@
 package interpolator
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
 	"log"
+	"log/slog"
 	"math"
-	"runtime"
 	"time"
+
+	"gthub.com/obzva/image-resize/parallel"
 )
 
 // initialize Interpolator
@@ -15,328 +18,410 @@ import (
 //   - nearestneighbor
 //   - bilinear
 //   - bicubic
+//   - catmullrom, mitchell, bspline and hermite, cubic filters with preset B and C parameters, see Cubic
+//   - cubic, the cubic filter with Options.B and Options.C
+//   - nedi, edge-directed interpolation for 2x enlargements, bicubic for any other size, see EdgeDirected
+//   - seamcarve, content-aware resizing removing or inserting seams, see SeamCarver
+//   - scale2x, scale3x, xbr2x, xbr3x and xbr4x, pixel-art scalers by an integer factor, see PixelArt
 func New(src *image.NRGBA, w, h int, method string) Interpolator {
-	var interpolator Interpolator
-
-	switch method {
-	case "nearestneighbor":
-		interpolator = &NearestNeighbor{src, image.NewNRGBA(image.Rect(0, 0, w, h))}
-	case "bilinear":
-		interpolator = &Bilinear{src, image.NewNRGBA(image.Rect(0, 0, w, h))}
-	case "bicubic":
-		interpolator = &Bicubic{src, image.NewNRGBA(image.Rect(0, 0, w, h))}
-	default:
-		log.Fatal("wrong interpolation method passed")
-	}
-
-	return interpolator
-}
-
-type Interpolator interface {
-	Interpolate(concurrency bool) *image.NRGBA
+	return NewWithOptions(src, w, h, method, Options{})
 }
 
-type NearestNeighbor struct {
-	input, output *image.NRGBA
-}
+// Options tunes an Interpolator, the zero value gives the same behavior as New
+type Options struct {
+	Workers   int                // number of goroutines in concurrency mode, defaults to runtime.NumCPU() when 0
+	Pool      *parallel.Pool     // worker pool shared with other interpolations, Workers is ignored when set
+	Partition parallel.Partition // how output pixels are split between workers, defaults to parallel.Flat
 
-// returns (x-axis scale, y-axis scale) = ((output width / input width), (output height / input height))
-func (nn *NearestNeighbor) getScale() (scaleX float64, scaleY float64) {
-	iW := nn.input.Bounds().Dx()
-	iH := nn.input.Bounds().Dy()
+	// optional callback receiving the number of completed output rows out of the output height
+	// throttled to one call per percent of rows, see parallel.Config
+	Progress func(done, total int)
 
-	oW := nn.output.Bounds().Dx()
-	oH := nn.output.Bounds().Dy()
+	// interpolate in linear light instead of blending sRGB-encoded values,
+	// so that averaging them matches how light mixes and high-contrast detail doesn't darken
+	// has no effect on nearestneighbor
+	Linear bool
 
-	return float64(oW) / float64(iW), float64(oH) / float64(iH)
-}
+	// how pixels outside of the input are read near its border, one of EdgeClamp, EdgeReflect, EdgeWrap
+	// and EdgeConstant, defaults to EdgeClamp when empty
+	Edge string
+	// color of the pixels outside of the input with EdgeConstant, transparent (black without alpha) when nil
+	EdgeColor color.Color
 
-// converts coordinates from output space to input space
-func (nn *NearestNeighbor) transformCoords(x, y int) (tX float64, tY float64) {
-	scaleX, scaleY := nn.getScale()
+	// parameters of the "cubic" method, larger B blurs more and larger C rings more, see Cubic
+	B, C float64
 
-	return float64(x) / scaleX, float64(y) / scaleY
+	// optional callback receiving Stats after every interpolation
+	Observer func(Stats)
 }
 
-func (nn *NearestNeighbor) operate(start, end int) {
-	oW := nn.output.Bounds().Dx()
+// Stats describes a finished interpolation
+type Stats struct {
+	Method   string        // interpolation method, as passed to New
+	Input    image.Point   // size of the input image
+	Output   image.Point   // size of the output image
+	Duration time.Duration // time spent interpolating
+	Workers  int           // number of goroutines used, 1 without concurrency
+	Err      error         // error returned by the interpolation, if any
+}
 
-	for ; start < end; start++ {
-		x := start % oW
-		y := start / oW
+func (s Stats) String() string {
+	str := fmt.Sprintf("%s interpolation %dx%d -> %dx%d with %d worker(s) took %v to run", s.Method, s.Input.X, s.Input.Y, s.Output.X, s.Output.Y, s.Workers, s.Duration)
+	if s.Err != nil {
+		str += fmt.Sprintf(" and failed: %v", s.Err)
+	}
+	return str
+}
 
-		tX, tY := nn.transformCoords(x, y)
+// SlogObserver returns an Observer that writes Stats to logger as structured attributes
+func SlogObserver(logger *slog.Logger) func(Stats) {
+	return func(s Stats) {
+		attrs := []slog.Attr{
+			slog.String("method", s.Method),
+			slog.Int("input_width", s.Input.X),
+			slog.Int("input_height", s.Input.Y),
+			slog.Int("output_width", s.Output.X),
+			slog.Int("output_height", s.Output.Y),
+			slog.Duration("duration", s.Duration),
+			slog.Int("workers", s.Workers),
+		}
 
-		nn.output.Set(x, y, nn.input.At(int(tX), int(tY)))
+		if s.Err != nil {
+			logger.LogAttrs(context.Background(), slog.LevelError, "interpolation failed", append(attrs, slog.Any("error", s.Err))...)
+			return
+		}
+		logger.LogAttrs(context.Background(), slog.LevelInfo, "interpolation done", attrs...)
 	}
 }
 
-func (nn *NearestNeighbor) Interpolate(concurrency bool) *image.NRGBA {
-	funcName := "Nearest neighbor"
-	if concurrency {
-		funcName += " with concurrency"
+// reports a finished interpolation to the Observer, if there is one
+// err points to the error returned by the interpolation
+func (o Options) observe(start time.Time, method string, concurrency bool, input, output image.Rectangle, err *error) {
+	if o.Observer == nil {
+		return
 	}
-	defer timeTrack(time.Now(), funcName)
-
-	oW := nn.output.Bounds().Dx()
-	oH := nn.output.Bounds().Dy()
 
+	workers := 1
 	if concurrency {
-		numCPU := runtime.NumCPU()
-		c := make(chan int, numCPU)
-
-		for i := range numCPU {
-			go func() {
-				nn.operate(i*oW*oH/numCPU, (i+1)*oW*oH/numCPU)
-				c <- 1
-			}()
-		}
-		// drain the channel
-		for i := 0; i < numCPU; i++ {
-			<-c
-		}
-		// all done
-	} else {
-		nn.operate(0, oW*oH)
+		workers = o.parallel().NumWorkers()
 	}
 
-	return nn.output
+	o.Observer(Stats{
+		Method:   method,
+		Input:    input.Size(),
+		Output:   output.Size(),
+		Duration: time.Since(start),
+		Workers:  workers,
+		Err:      *err,
+	})
 }
 
-type Bilinear struct {
-	input, output *image.NRGBA
+// returns the parallel.Config used in concurrency mode
+func (o Options) parallel() parallel.Config {
+	return parallel.Config{
+		Workers:   o.Workers,
+		Pool:      o.Pool,
+		Partition: o.Partition,
+		Progress:  o.Progress,
+	}
 }
 
-// returns (x-axis scale, y-axis scale) = ((output width / input width), (output height / input height))
-func (bl *Bilinear) getScale() (scaleX float64, scaleY float64) {
-	iW := bl.input.Bounds().Dx()
-	iH := bl.input.Bounds().Dy()
+// initialize Interpolator with Options
+func NewWithOptions(src *image.NRGBA, w, h int, method string, opts Options) Interpolator {
+	return NewImage(src, w, h, method, opts)
+}
+
+// initialize Interpolator with any kind of source image
+// *image.NRGBA, *image.NRGBA64, *image.Gray and *image.YCbCr are read in place, other images are converted into
+// *image.NRGBA64 when they have 16 bits per channel and into *image.NRGBA otherwise
+// InterpolateImage returns an output of the same kind, *image.YCbCr keeps its chroma subsampling,
+// except in linear light and with the pixel-art and seamcarve methods, which convert it into *image.NRGBA
+func NewImage(src image.Image, w, h int, method string, opts Options) Interpolator {
+	// pixel-art methods compare whole colors, seams run through all planes at once,
+	// and Y, Cb and Cr are not sRGB-encoded, so that they can't be blended in linear light
+	_, pixelArt := pixelArtScales[method]
+	linear := opts.Linear && method != "nearestneighbor"
+	if src, ok := src.(*image.YCbCr); ok && (pixelArt || method == "seamcarve" || linear) {
+		return NewImage(ToNRGBA(src), w, h, method, opts)
+	}
+
+	if src, ok := src.(*image.YCbCr); ok && src.Rect.Min == (image.Point{}) && src.SubsampleRatio != image.YCbCrSubsampleRatio444 {
+		return newYCbCr(src, w, h, method, opts)
+	}
+
+	// nearest neighbor copies pixels as they are, there is nothing to blend in linear light,
+	// and pixel-art methods match exact colors
+	linear = linear && !pixelArt
 
-	oW := bl.output.Bounds().Dx()
-	oH := bl.output.Bounds().Dy()
+	input := newRaster(src, linear)
 
-	return float64(oW) / float64(iW), float64(oH) / float64(iH)
+	return newInterpolator(input, newRasterLike(input, w, h, linear), method, opts)
 }
 
-// converts coordinates from output space to input space
-func (bl *Bilinear) transformCoords(x, y int) (tX float64, tY float64) {
-	scaleX, scaleY := bl.getScale()
+// interpolation methods of New, see newInterpolator, Validate and CanWarp
+var methods = map[string]struct {
+	// initializes the interpolator, scale maps output coordinates onto input coordinates
+	new func(input, output raster, scale Scale, method string, opts Options) Interpolator
+	// reads the input at any point, so that it can sample NewWarp and NewRotate
+	warp bool
+}{
+	"nearestneighbor": {newNearestNeighbor, true},
+	"bilinear":        {newBilinear, true},
+	"bicubic":         {newBicubic, true},
+	"cubic":           {newCubic, true},
+	"catmullrom":      {newCubic, true},
+	"mitchell":        {newCubic, true},
+	"bspline":         {newCubic, true},
+	"hermite":         {newCubic, true},
+	"nedi":            {newEdgeDirectedScale, false},
+	"seamcarve":       {newSeamCarver, false},
+	"scale2x":         {newPixelArtScale, false},
+	"scale3x":         {newPixelArtScale, false},
+	"xbr2x":           {newPixelArtScale, false},
+	"xbr3x":           {newPixelArtScale, false},
+	"xbr4x":           {newPixelArtScale, false},
+}
 
-	offsetX := getOffset(scaleX)
-	offsetY := getOffset(scaleY)
+func newNearestNeighbor(input, output raster, scale Scale, method string, opts Options) Interpolator {
+	nn := &NearestNeighbor{input: input, output: output, transform: cornerScale(scale)}
+	nn.interpolation = newInterpolation(method, input.Bounds(), output.Bounds(), opts, nn.run)
+	return nn
+}
 
-	return float64(x)/scaleX - offsetX, float64(y)/scaleY - offsetY
+func newBilinear(input, output raster, scale Scale, method string, opts Options) Interpolator {
+	bl := &Bilinear{input: input, output: output, transform: scale}
+	bl.interpolation = newInterpolation(method, input.Bounds(), output.Bounds(), opts, bl.run)
+	return bl
 }
 
-// calculates the weighted average of two points(nV and nV+1) for each color channel (RGBA) about v
-// pR, pG, pB, pA: color values at two points (index 0: color value of nV, index 1: color value of nV+1)
-// nV: largest integer value no larger than v
-func (bl *Bilinear) internalDivision(pR, pG, pB, pA *[2]float64, nV, v float64) (r float64, g float64, b float64, a float64) {
-	r = (nV+1-v)*float64(pR[0]) + (v-nV)*float64(pR[1])
-	g = (nV+1-v)*float64(pG[0]) + (v-nV)*float64(pG[1])
-	b = (nV+1-v)*float64(pB[0]) + (v-nV)*float64(pB[1])
-	a = (nV+1-v)*float64(pA[0]) + (v-nV)*float64(pA[1])
+func newBicubic(input, output raster, scale Scale, method string, opts Options) Interpolator {
+	bc := &Bicubic{input: input, output: output, transform: scale}
+	bc.interpolation = newInterpolation(method, input.Bounds(), output.Bounds(), opts, bc.run)
+	return bc
+}
 
-	return r, g, b, a
+func newCubic(input, output raster, scale Scale, method string, opts Options) Interpolator {
+	bc, ok := cubicPresets[method]
+	if !ok {
+		bc = [2]float64{opts.B, opts.C}
+	}
+	cu := &Cubic{input: input, output: output, transform: scale, b: bc[0], c: bc[1]}
+	cu.interpolation = newInterpolation(method, input.Bounds(), output.Bounds(), opts, cu.run)
+	return cu
 }
 
-func (bl *Bilinear) operate(start, end int) {
-	iW := bl.input.Bounds().Dx()
-	iH := bl.input.Bounds().Dy()
+func newEdgeDirectedScale(input, output raster, scale Scale, method string, opts Options) Interpolator {
+	return newEdgeDirected(input, output, opts)
+}
 
-	oW := bl.output.Bounds().Dx()
+func newSeamCarver(input, output raster, scale Scale, method string, opts Options) Interpolator {
+	sc := &SeamCarver{input: input, output: output}
+	sc.interpolation = newInterpolation(method, input.Bounds(), output.Bounds(), opts, sc.run)
+	return sc
+}
 
-	for ; start < end; start++ {
-		x := start % oW
-		y := start / oW
+func newPixelArtScale(input, output raster, scale Scale, method string, opts Options) Interpolator {
+	return newPixelArt(input, output, method, opts)
+}
 
-		// transformed x and y
-		tX, tY := bl.transformCoords(x, y)
-
-		// boundary check
-		outX := tX < 0 || tX > float64(iW-1)
-		outY := tY < 0 || tY > float64(iH-1)
-
-		var iC color.NRGBA
-
-		// meaning of prefix
-		// n: nearest (largest integer value no larger than ...)
-		// l: left
-		// r: right
-		// t: top
-		// b: bottom
-
-		// use just one nearest surrounding point
-		if outX && outY {
-			var nX int
-			var nY int
-
-			if tX < 0 {
-				nX = 0
-			} else {
-				nX = iW - 1
-			}
+// initialize Interpolator reading from input and writing into output
+func newInterpolator(input, output raster, method string, opts Options) Interpolator {
+	m, ok := methods[method]
+	if !ok {
+		log.Fatal("wrong interpolation method passed")
+	}
 
-			if tY < 0 {
-				nY = 0
-			} else {
-				nY = iH - 1
-			}
+	scale := scaleOf(input, output)
 
-			iC = bl.input.NRGBAAt(nX, nY)
-		} else if outX { // use two surrounding points (only y-axis)
-			var nX float64
-			if tX < 0 {
-				nX = 0
-			} else {
-				nX = float64(iW - 1)
-			}
+	// samples near the border read outside of the input
+	return m.new(withEdges(input, opts), output, scale, method, opts)
+}
 
-			nY := math.Floor(tY)
+// Validate reports an error when New doesn't know method, or when method can't resize an input of size input
+// into output, such as pixel-art methods returning ErrScale, so that callers can check them before processing anything
+func Validate(method string, input, output image.Point) error {
+	if output.X <= 0 || output.Y <= 0 {
+		return fmt.Errorf("invalid output size %dx%d", output.X, output.Y)
+	}
 
-			// color values at two points (nX, nY) and (nX, nY+1) for each color channel (RGBA)
-			// index 0: color values at (nX, nY)
-			// index 1: color values at (nX, nY+1)
-			var pR, pG, pB, pA [2]float64
+	if _, ok := methods[method]; !ok {
+		return fmt.Errorf("unknown interpolation method %q", method)
+	}
+	return validatePixelArtScale(method, input, output)
+}
 
-			for i := range 2 {
-				pRGBA := bl.input.NRGBAAt(int(nX), int(nY)+i)
-				pR[i] = float64(pRGBA.R)
-				pG[i] = float64(pRGBA.G)
-				pB[i] = float64(pRGBA.B)
-				pA[i] = float64(pRGBA.A)
-			}
+// Validate reports an error when o can't be used with method, such as an unknown Edge or B and C parameters
+// of the cubic method outside of [0, 1], so that callers can check them before processing anything
+func (o Options) Validate(method string) error {
+	switch o.Edge {
+	case "", EdgeClamp, EdgeReflect, EdgeWrap, EdgeConstant:
+	default:
+		return fmt.Errorf("unknown edge mode %q, expected clamp, reflect, wrap or constant", o.Edge)
+	}
 
-			iR, iG, iB, iA := bl.internalDivision(&pR, &pG, &pB, &pA, nY, tY)
+	// only the cubic method reads B and C, the presets have their own
+	if method == "cubic" && !(o.B >= 0 && o.B <= 1 && o.C >= 0 && o.C <= 1) {
+		return fmt.Errorf("invalid cubic parameters B=%v and C=%v, expected both within [0, 1]", o.B, o.C)
+	}
 
-			iC = color.NRGBA{clamp(iR), clamp(iG), clamp(iB), clamp(iA)}
-		} else if outY { // use two surrounding points (only x-axis)
-			var nY float64
+	return nil
+}
 
-			if tY < 0 {
-				nY = 0
-			} else {
-				nY = float64(iH - 1)
-			}
+// CanWarp reports whether method can sample NewWarp and NewRotate
+func CanWarp(method string) bool {
+	return methods[method].warp
+}
 
-			nX := math.Floor(tX)
+type Interpolator interface {
+	Interpolate(concurrency bool) *image.NRGBA
+	// same as Interpolate, but stops early and returns ctx.Err() once ctx is done
+	InterpolateContext(ctx context.Context, concurrency bool) (*image.NRGBA, error)
+	// same as InterpolateContext, but returns the output in the kind of the source image,
+	// *image.NRGBA64 for 16-bit sources for example
+	InterpolateImage(ctx context.Context, concurrency bool) (image.Image, error)
+}
 
-			// color values at two points (nX, nY) and (nX+1, nY) for each color channel (RGBA)
-			// index 0: color values at (nX, nY)
-			// index 1: color values at (nX+1, nY)
-			var pR, pG, pB, pA [2]float64
+// interpolation implements the entry points of Interpolator around the run of one method,
+// so that all of them report to the Observer and convert their output the same way
+type interpolation struct {
+	method                    string // reported to the Observer
+	inputBounds, outputBounds image.Rectangle
+	opts                      Options
 
-			for i := range 2 {
-				pRGBA := bl.input.NRGBAAt(int(nX)+i, int(nY))
-				pR[i] = float64(pRGBA.R)
-				pG[i] = float64(pRGBA.G)
-				pB[i] = float64(pRGBA.B)
-				pA[i] = float64(pRGBA.A)
-			}
+	// interpolates the whole output and returns it in the kind of the source image
+	run func(ctx context.Context, concurrency bool) (image.Image, error)
+}
 
-			iR, iG, iB, iA := bl.internalDivision(&pR, &pG, &pB, &pA, nX, tX)
-
-			iC = color.NRGBA{clamp(iR), clamp(iG), clamp(iB), clamp(iA)}
-		} else { // use four surrounding points (both x-axis and y-axis)
-			nX := math.Floor(tX)
-			nY := math.Floor(tY)
-
-			// color values at four points (nX, nY), (nX+1, nY), (nX, nY+1) and (nX+1, nY+1) for each color channel (RGBA)
-			// index [0][0]: color values at (nX, nY)
-			// index [0][1]: color values at (nX+1, nY)
-			// index [1][0]: color values at (nX, nY+1)
-			// index [1][1]: color values at (nX+1, nY+1)
-			var pR, pG, pB, pA [2][2]float64
-
-			// temporarily saved color values got from internal division on x-axis
-			// index 0: values got from internal division on y=nY
-			// index 1: values got from internal division on y=nY+1
-			var tmpR, tmpG, tmpB, tmpA [2]float64
-
-			for i := range 2 {
-				for j := range 2 {
-					pRGBA := bl.input.NRGBAAt(int(nX)+j, int(nY)+i)
-					pR[i][j] = float64(pRGBA.R)
-					pG[i][j] = float64(pRGBA.G)
-					pB[i][j] = float64(pRGBA.B)
-					pA[i][j] = float64(pRGBA.A)
-				}
-				tmpR[i], tmpG[i], tmpB[i], tmpA[i] = bl.internalDivision(&pR[i], &pG[i], &pB[i], &pA[i], nX, tX)
-			}
+func newInterpolation(method string, input, output image.Rectangle, opts Options, run func(ctx context.Context, concurrency bool) (image.Image, error)) interpolation {
+	return interpolation{method: method, inputBounds: input, outputBounds: output, opts: opts, run: run}
+}
 
-			iR, iG, iB, iA := bl.internalDivision(&tmpR, &tmpG, &tmpB, &tmpA, nY, tY)
+func (it *interpolation) Interpolate(concurrency bool) *image.NRGBA {
+	output, _ := it.InterpolateContext(context.Background(), concurrency)
+	return output
+}
 
-			iC = color.NRGBA{clamp(iR), clamp(iG), clamp(iB), clamp(iA)}
-		}
-		bl.output.Set(x, y, iC)
+func (it *interpolation) InterpolateContext(ctx context.Context, concurrency bool) (*image.NRGBA, error) {
+	output, err := it.InterpolateImage(ctx, concurrency)
+	if err != nil {
+		return nil, err
 	}
+	return ToNRGBA(output), nil
 }
 
-func (bl *Bilinear) Interpolate(concurrency bool) *image.NRGBA {
-	funcName := "Bilinear"
-	if concurrency {
-		funcName += " with concurrency"
-	}
-	defer timeTrack(time.Now(), funcName)
+func (it *interpolation) InterpolateImage(ctx context.Context, concurrency bool) (output image.Image, err error) {
+	defer it.opts.observe(time.Now(), it.method, concurrency, it.inputBounds, it.outputBounds, &err)
 
-	oW := bl.output.Bounds().Dx()
-	oH := bl.output.Bounds().Dy()
+	return it.run(ctx, concurrency)
+}
 
-	if concurrency {
-		numCPU := runtime.NumCPU()
-		c := make(chan int, numCPU)
-
-		for i := range numCPU {
-			go func() {
-				bl.operate(i*oW*oH/numCPU, (i+1)*oW*oH/numCPU)
-				c <- 1
-			}()
-		}
-		// drain the channel
-		for i := 0; i < numCPU; i++ {
-			<-c
-		}
-		// all done
-	} else {
-		bl.operate(0, oW*oH)
+// writes s sampled at every output pixel mapped onto the input by t into output, and returns the output image
+func resample(ctx context.Context, concurrency bool, opts Options, output raster, t Transform, s sampler) (image.Image, error) {
+	oW := output.Bounds().Dx()
+	oH := output.Bounds().Dy()
+
+	err := parallel.RunPixels(ctx, concurrency, opts.parallel(), oW, oH, func(x, y int) {
+		// transformed x and y
+		tX, tY := t.Map(float64(x), float64(y))
+
+		output.set(x, y, s.sample(tX, tY))
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return bl.output
+	return output.image(), nil
 }
 
-type Bicubic struct {
-	input, output *image.NRGBA
+type NearestNeighbor struct {
+	interpolation
+	input, output raster
+	transform     Transform // maps output coordinates onto input coordinates
 }
 
-// returns (x-axis scale, y-axis scale) = ((output width / input width), (output height / input height))
-func (bc *Bicubic) getScale() (scaleX float64, scaleY float64) {
-	iW := bc.input.Bounds().Dx()
-	iH := bc.input.Bounds().Dy()
+// returns the color values of the input pixel nearest to (tX, tY)
+// integer coordinates are the centers of input pixels
+func (nn *NearestNeighbor) sample(tX, tY float64) [4]float64 {
+	return nn.input.at(int(math.Floor(tX+0.5)), int(math.Floor(tY+0.5)))
+}
+
+func (nn *NearestNeighbor) run(ctx context.Context, concurrency bool) (image.Image, error) {
+	return resample(ctx, concurrency, nn.opts, nn.output, nn.transform, nn)
+}
+
+type Bilinear struct {
+	interpolation
+	input, output raster
+	transform     Transform // maps output coordinates onto input coordinates
+}
 
-	oW := bc.output.Bounds().Dx()
-	oH := bc.output.Bounds().Dy()
+// reads the color values at (x, y) of the input image in the working space of the interpolation
+func (bl *Bilinear) at(x, y int) [4]float64 {
+	return bl.input.at(x, y)
+}
+
+// calculates the weighted average of two points(nV and nV+1) for the first n color channels about v
+// p: color values at two points (index 0: color values of nV, index 1: color values of nV+1)
+// nV: largest integer value no larger than v
+func (bl *Bilinear) internalDivision(p *[2][4]float64, n int, nV, v float64) (c [4]float64) {
+	for i := range n {
+		c[i] = (nV+1-v)*p[0][i] + (v-nV)*p[1][i]
+	}
 
-	return float64(oW) / float64(iW), float64(oH) / float64(iH)
+	return c
 }
 
-// converts coordinates from output space to input space
-func (bc *Bicubic) transformCoords(x, y int) (tX float64, tY float64) {
-	scaleX, scaleY := bc.getScale()
+// calculates the color values at (tX, tY) of the input space
+// integer coordinates are the centers of input pixels, points outside of the input are read by the edge mode
+func (bl *Bilinear) sample(tX, tY float64) [4]float64 {
+	// number of color channels to interpolate
+	n := bl.input.channels()
+
+	// meaning of prefix
+	// n: nearest (largest integer value no larger than ...)
+	nX := math.Floor(tX)
+	nY := math.Floor(tY)
+
+	// color values at four points (nX, nY), (nX+1, nY), (nX, nY+1) and (nX+1, nY+1)
+	// index [0][0]: color values at (nX, nY)
+	// index [0][1]: color values at (nX+1, nY)
+	// index [1][0]: color values at (nX, nY+1)
+	// index [1][1]: color values at (nX+1, nY+1)
+	var p [2][2][4]float64
+
+	// temporarily saved color values got from internal division on x-axis
+	// index 0: values got from internal division on y=nY
+	// index 1: values got from internal division on y=nY+1
+	var tmp [2][4]float64
+
+	for i := range 2 {
+		for j := range 2 {
+			p[i][j] = bl.at(int(nX)+j, int(nY)+i)
+		}
+		tmp[i] = bl.internalDivision(&p[i], n, nX, tX)
+	}
+
+	return bl.internalDivision(&tmp, n, nY, tY)
+}
 
-	offsetX := getOffset(scaleX)
-	offsetY := getOffset(scaleY)
+func (bl *Bilinear) run(ctx context.Context, concurrency bool) (image.Image, error) {
+	return resample(ctx, concurrency, bl.opts, bl.output, bl.transform, bl)
+}
 
-	return float64(x)/scaleX - offsetX, float64(y)/scaleY - offsetY
+type Bicubic struct {
+	interpolation
+	input, output raster
+	transform     Transform // maps output coordinates onto input coordinates
 }
 
 // interpolates a value f(v) that function f(t) takes at ordinate t=v
 // for more detail of formula, please refer to https://en.wikipedia.org/wiki/Cubic_Hermite_spline#Interpolation_on_the_unit_interval_with_matched_derivatives_at_endpoints
 // u: fractional part of v
-// p: color values at four points(p_n-1, p_n, p_n+1, p_n+2) for each color channel (RGBA)
-//   - index 0: color values at p_n-1
-//   - index 1: color values at p_n
-//   - index 2: color values at p_n+1
-//   - index 3: color values at p_n+2
+// p: values of a single color channel at four points(p_n-1, p_n, p_n+1, p_n+2)
+//   - index 0: value at p_n-1
+//   - index 1: value at p_n
+//   - index 2: value at p_n+1
+//   - index 3: value at p_n+2
 func (bc *Bicubic) catmullRomSpline(u float64, p *[4]float64) float64 {
 	u2 := u * u
 	u3 := u2 * u
@@ -349,204 +434,117 @@ func (bc *Bicubic) catmullRomSpline(u float64, p *[4]float64) float64 {
 	return 0.5 * (term1 + term2 + term3 + term4)
 }
 
-func (bc *Bicubic) operate(start, end int) {
+// calculates the color values at (tX, tY) of the input space, x first y later
+// integer coordinates are the centers of input pixels
+// with EdgeClamp, an axis less than a pixel away from the border isn't interpolated, its nearest pixel is read instead,
+// other edge modes read the points outside of the input by the mode
+func (bc *Bicubic) sample(tX, tY float64) [4]float64 {
 	iW := bc.input.Bounds().Dx()
 	iH := bc.input.Bounds().Dy()
 
-	oW := bc.output.Bounds().Dx()
+	// number of color channels to interpolate
+	n := bc.input.channels()
 
-	for ; start < end; start++ {
-		x := start % oW
-		y := start / oW
+	// boundary check
+	clamped := edgeMode(bc.input) == EdgeClamp
+	outX := clamped && (tX < 1 || tX > float64(iW-2))
+	outY := clamped && (tY < 1 || tY > float64(iH-2))
 
-		// transformed x and y
-		tX, tY := bc.transformCoords(x, y)
-
-		// boundary check
-		outX := tX < 1 || tX > float64(iW-2)
-		outY := tY < 1 || tY > float64(iH-2)
-
-		var iC color.NRGBA
-
-		// use just one nearest surrounding point
-		if outX && outY {
-			var nX int
-			var nY int
-
-			if tX < 0.5 {
-				nX = 0
-			} else if tX < 1 {
-				nX = 1
-			} else if tX <= float64(iW)-1.5 {
-				nX = iW - 2
-			} else {
-				nX = iW - 1
-			}
+	floorX := math.Floor(tX)
+	fractionX := tX - floorX
 
-			if tY < 0.5 {
-				nY = 0
-			} else if tY < 1 {
-				nY = 1
-			} else if tY <= float64(iH)-1.5 {
-				nY = iH - 2
-			} else {
-				nY = iH - 1
-			}
-
-			iC = bc.input.NRGBAAt(nX, nY)
-		} else if outX { // use only y-axis
-			var nX int
-
-			if tX < 0.5 {
-				nX = 0
-			} else if tX < 1 {
-				nX = 1
-			} else if tX <= float64(iW)-1.5 {
-				nX = iW - 2
-			} else {
-				nX = iW - 1
-			}
-
-			floorY := math.Floor(tY)
-			fractionY := tY - floorY
+	intX := int(floorX)
 
-			intY := int(floorY)
+	floorY := math.Floor(tY)
+	fractionY := tY - floorY
 
-			var pR, pG, pB, pA [4]float64
+	intY := int(floorY)
 
-			for i := range 4 {
-				pR[i] = float64(bc.input.NRGBAAt(nX, intY-1+i).R)
-				pG[i] = float64(bc.input.NRGBAAt(nX, intY-1+i).G)
-				pB[i] = float64(bc.input.NRGBAAt(nX, intY-1+i).B)
-				pA[i] = float64(bc.input.NRGBAAt(nX, intY-1+i).A)
-			}
-
-			iR := clamp(bc.catmullRomSpline(fractionY, &pR))
-			iG := clamp(bc.catmullRomSpline(fractionY, &pG))
-			iB := clamp(bc.catmullRomSpline(fractionY, &pB))
-			iA := clamp(bc.catmullRomSpline(fractionY, &pA))
-
-			iC = color.NRGBA{iR, iG, iB, iA}
-		} else if outY { // use only x-axis
-			var nY int
-
-			if tY < 0.5 {
-				nY = 0
-			} else if tY < 1 {
-				nY = 1
-			} else if tY <= float64(iH)-1.5 {
-				nY = iH - 2
-			} else {
-				nY = iH - 1
-			}
+	// the 4x4 points around (tX, tY) are read without edge handling when they all lie inside of the input
+	input := bc.input
+	if e, ok := input.(*edgeRaster); ok && !outX && !outY {
+		input = e.window(intX-1, intY-1, intX+2, intY+2)
+	}
 
-			floorX := math.Floor(tX)
-			fractionX := tX - floorX
+	// rows read, only the nearest one when the y-axis is not interpolated
+	rows := 4
+	if outY {
+		rows = 1
+	}
 
-			intX := int(floorX)
+	// values of each color channel got from the spline on x-axis, one for each of the rows
+	var tmp [4][4]float64
 
-			var pR, pG, pB, pA [4]float64
+	for i := range rows {
+		y := intY - 1 + i
+		if outY {
+			y = borderIndex(tY, iH)
+		}
 
-			for i := range 4 {
-				pR[i] = float64(bc.input.NRGBAAt(intX-1+i, nY).R)
-				pG[i] = float64(bc.input.NRGBAAt(intX-1+i, nY).G)
-				pB[i] = float64(bc.input.NRGBAAt(intX-1+i, nY).B)
-				pA[i] = float64(bc.input.NRGBAAt(intX-1+i, nY).A)
+		// use only the nearest point of the row
+		if outX {
+			pRGBA := input.at(borderIndex(tX, iW), y)
+			for c := range n {
+				tmp[c][i] = pRGBA[c]
 			}
+			continue
+		}
 
-			iR := clamp(bc.catmullRomSpline(fractionX, &pR))
-			iG := clamp(bc.catmullRomSpline(fractionX, &pG))
-			iB := clamp(bc.catmullRomSpline(fractionX, &pB))
-			iA := clamp(bc.catmullRomSpline(fractionX, &pA))
-
-			iC = color.NRGBA{iR, iG, iB, iA}
-		} else { // use both two axes, x first y later
-			floorX := math.Floor(tX)
-			fractionX := tX - floorX
-
-			intX := int(floorX)
-
-			floorY := math.Floor(tY)
-			fractionY := tY - floorY
-
-			intY := int(floorY)
-
-			var tmpR, tmpG, tmpB, tmpA [4]float64
-			var pR, pG, pB, pA [4][4]float64
-
-			for i := range 4 {
-				for j := range 4 {
-					pR[i][j] = float64(bc.input.NRGBAAt(intX-1+j, intY-1+i).R)
-					pG[i][j] = float64(bc.input.NRGBAAt(intX-1+j, intY-1+i).G)
-					pB[i][j] = float64(bc.input.NRGBAAt(intX-1+j, intY-1+i).B)
-					pA[i][j] = float64(bc.input.NRGBAAt(intX-1+j, intY-1+i).A)
-				}
+		// values of each color channel at four points of the row y
+		var p [4][4]float64
 
-				tmpR[i] = bc.catmullRomSpline(fractionX, &pR[i])
-				tmpG[i] = bc.catmullRomSpline(fractionX, &pG[i])
-				tmpB[i] = bc.catmullRomSpline(fractionX, &pB[i])
-				tmpA[i] = bc.catmullRomSpline(fractionX, &pA[i])
+		for j := range 4 {
+			pRGBA := input.at(intX-1+j, y)
+			for c := range n {
+				p[c][j] = pRGBA[c]
 			}
+		}
 
-			iR := clamp(bc.catmullRomSpline(fractionY, &tmpR))
-			iG := clamp(bc.catmullRomSpline(fractionY, &tmpG))
-			iB := clamp(bc.catmullRomSpline(fractionY, &tmpB))
-			iA := clamp(bc.catmullRomSpline(fractionY, &tmpA))
-
-			iC = color.NRGBA{iR, iG, iB, iA}
+		for c := range n {
+			tmp[c][i] = bc.catmullRomSpline(fractionX, &p[c])
 		}
-		bc.output.Set(x, y, iC)
 	}
-}
 
-func (bc *Bicubic) Interpolate(concurrency bool) *image.NRGBA {
-	funcName := "Bicubic"
-	if concurrency {
-		funcName += " with concurrency"
+	var iC [4]float64
+	for c := range n {
+		if outY {
+			iC[c] = tmp[c][0]
+		} else {
+			iC[c] = bc.catmullRomSpline(fractionY, &tmp[c])
+		}
 	}
-	defer timeTrack(time.Now(), funcName)
 
-	oW := bc.output.Bounds().Dx()
-	oH := bc.output.Bounds().Dy()
+	return iC
+}
 
-	if concurrency {
-		numCPU := runtime.NumCPU()
-		c := make(chan int, numCPU)
-
-		for i := range numCPU {
-			go func() {
-				bc.operate(i*oW*oH/numCPU, (i+1)*oW*oH/numCPU)
-				c <- 1
-			}()
-		}
-		// drain the channel
-		for i := 0; i < numCPU; i++ {
-			<-c
-		}
-		// all done
-	} else {
-		bc.operate(0, oW*oH)
+// returns the pixel read by Bicubic at t on an axis of n pixels, when t is less than a pixel away from the border
+func borderIndex(t float64, n int) int {
+	if t < 0.5 {
+		return 0
+	} else if t < 1 {
+		return 1
+	} else if t <= float64(n)-1.5 {
+		return n - 2
 	}
-
-	return bc.output
+	return n - 1
 }
 
-// helpers
-func timeTrack(start time.Time, funcName string) {
-	elapsed := time.Since(start)
-	fmt.Printf("%s interpolation took %v to run\n", funcName, elapsed)
+func (bc *Bicubic) run(ctx context.Context, concurrency bool) (image.Image, error) {
+	return resample(ctx, concurrency, bc.opts, bc.output, bc.transform, bc)
 }
 
+// helpers
 func getOffset(scale float64) float64 {
 	return (scale - 1) / (2 * scale)
 }
 
-func clamp(v float64) uint8 {
-	if v > 255 { // overshoot
-		return 255
+// rounds v to the nearest value of the channel type T
+func clamp[T uint8 | uint16](v float64) T {
+	if maxV := float64(^T(0)); v > maxV { // overshoot
+		return ^T(0)
 	} else if v < 0 { // undershoot
 		return 0
 	} else {
-		return uint8(math.Round(v))
+		return T(math.Round(v))
 	}
 }
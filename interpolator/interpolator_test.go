@@ -1,9 +1,17 @@
 package interpolator
 
 import (
+	"context"
+	"errors"
 	"image"
 	"image/color"
+	"image/jpeg"
+	"math"
+	"os"
 	"testing"
+
+	"gthub.com/obzva/image-resize/metrics"
+	"gthub.com/obzva/image-resize/parallel"
 )
 
 func TestNearestNeighbor(t *testing.T) {
@@ -214,6 +222,391 @@ func TestBilinear(t *testing.T) {
 	}
 }
 
+func TestLinear(t *testing.T) {
+	// 1px black and white checkerboard
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := range 8 {
+		for x := range 8 {
+			if (x+y)%2 == 0 {
+				src.Set(x, y, color.NRGBA{255, 255, 255, 255})
+			} else {
+				src.Set(x, y, color.NRGBA{0, 0, 0, 255})
+			}
+		}
+	}
+
+	// half of the light of white is mid-grey luminance, 188 once encoded in sRGB
+	// blending sRGB values directly gives 128, which looks much darker
+	for _, tt := range []struct {
+		linear   bool
+		expected uint8
+	}{
+		{false, 128},
+		{true, 188},
+	} {
+		actual := NewWithOptions(src, 4, 4, "bilinear", Options{Linear: tt.linear}).Interpolate(false)
+
+		for y := range 4 {
+			for x := range 4 {
+				c := actual.NRGBAAt(x, y)
+				if absDiff(c.R, tt.expected) > 1 || c.R != c.G || c.R != c.B || c.A != 255 {
+					t.Errorf("linear: %t: expected RGBA at [%d, %d] to be [%d±1, %d±1, %d±1, 255] but instead got %v", tt.linear, x, y, tt.expected, tt.expected, tt.expected, c)
+				}
+			}
+		}
+	}
+}
+
+func TestLinearRoundTrip(t *testing.T) {
+	r := &nrgbaRaster{image.NewNRGBA(image.Rect(0, 0, 1, 1)), true}
+	for v := range 256 {
+		c := color.NRGBA{uint8(v), uint8(v), uint8(v), 255}
+		r.img.SetNRGBA(0, 0, c)
+		r.set(0, 0, r.at(0, 0))
+		if actual := r.img.NRGBAAt(0, 0); actual != c {
+			t.Errorf("expected %v to survive the round trip through linear light but instead got %v", c, actual)
+		}
+	}
+}
+
+func TestLinearRoundTrip16(t *testing.T) {
+	// the darkest tones are the ones a coarse encoding into sRGB loses
+	r := &nrgba64Raster{image.NewNRGBA64(image.Rect(0, 0, 1, 1)), true}
+	for v := range 4096 {
+		c := color.NRGBA64{uint16(v), uint16(v), uint16(v), 65535}
+		r.img.SetNRGBA64(0, 0, c)
+		r.set(0, 0, r.at(0, 0))
+		if actual := r.img.NRGBA64At(0, 0); actual != c {
+			t.Errorf("expected %v to survive the round trip through linear light but instead got %v", c, actual)
+		}
+	}
+}
+
+func TestPremultipliedAlpha(t *testing.T) {
+	// opaque red sprite in the middle of a transparent background
+	// whose color values are garbage (transparent green)
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := range 4 {
+		for x := range 4 {
+			if x == 0 || x == 3 || y == 0 || y == 3 {
+				src.Set(x, y, color.NRGBA{0, 255, 0, 0})
+			} else {
+				src.Set(x, y, color.NRGBA{255, 0, 0, 255})
+			}
+		}
+	}
+
+	for _, method := range []string{"bilinear", "bicubic"} {
+		for _, linear := range []bool{false, true} {
+			actual := NewWithOptions(src, 10, 10, method, Options{Linear: linear}).Interpolate(false)
+
+			// every visible pixel has to stay pure red, no green fringe at the edges
+			for y := range 10 {
+				for x := range 10 {
+					c := actual.NRGBAAt(x, y)
+					if c.A == 0 {
+						continue
+					}
+					if c.R < 254 || c.G > 1 || c.B > 1 {
+						t.Errorf("%s (linear: %t): expected RGB at [%d, %d] to be [255, 0, 0] but instead got %v", method, linear, x, y, c)
+					}
+				}
+			}
+
+			// the sprite has to fade out towards the transparent background
+			if a := actual.NRGBAAt(5, 5).A; a != 255 {
+				t.Errorf("%s (linear: %t): expected alpha at the center to be 255 but instead got %d", method, linear, a)
+			}
+			if a := actual.NRGBAAt(0, 0).A; a != 0 {
+				t.Errorf("%s (linear: %t): expected alpha at the corner to be 0 but instead got %d", method, linear, a)
+			}
+		}
+	}
+}
+
+func TestNRGBA64(t *testing.T) {
+	// two values that are the same in 8 bits
+	src := image.NewNRGBA64(image.Rect(0, 0, 2, 1))
+	src.SetNRGBA64(0, 0, color.NRGBA64{1000, 1000, 1000, 65535})
+	src.SetNRGBA64(1, 0, color.NRGBA64{1100, 1100, 1100, 65535})
+
+	for _, method := range []string{"nearestneighbor", "bilinear", "bicubic"} {
+		output, err := NewImage(src, 4, 1, method, Options{}).InterpolateImage(context.Background(), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, ok := output.(*image.NRGBA64)
+		if !ok {
+			t.Fatalf("%s: expected *image.NRGBA64 output but instead got %T", method, output)
+		}
+
+		// the edges keep the source values, the middle lies in between
+		for x, expected := range [4][2]uint16{{1000, 1000}, {1000, 1050}, {1050, 1100}, {1100, 1100}} {
+			c := actual.NRGBA64At(x, 0)
+			if c.R < expected[0] || c.R > expected[1] || c.R != c.G || c.R != c.B || c.A != 65535 {
+				t.Errorf("%s: expected R at [%d, 0] to be between %d and %d but instead got %v", method, x, expected[0], expected[1], c)
+			}
+		}
+	}
+}
+
+func TestGray(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 2, 1))
+	src.SetGray(0, 0, color.Gray{0})
+	src.SetGray(1, 0, color.Gray{255})
+
+	for _, method := range []string{"nearestneighbor", "bilinear", "bicubic"} {
+		output, err := NewImage(src, 4, 1, method, Options{}).InterpolateImage(context.Background(), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, ok := output.(*image.Gray)
+		if !ok {
+			t.Fatalf("%s: expected *image.Gray output but instead got %T", method, output)
+		}
+
+		if actual.GrayAt(0, 0).Y != 0 || actual.GrayAt(3, 0).Y != 255 || actual.GrayAt(1, 0).Y > actual.GrayAt(2, 0).Y {
+			t.Errorf("%s: expected a ramp from 0 to 255 but instead got %v", method, actual.Pix)
+		}
+	}
+}
+
+func TestYCbCr(t *testing.T) {
+	for _, ratio := range []image.YCbCrSubsampleRatio{image.YCbCrSubsampleRatio444, image.YCbCrSubsampleRatio420, image.YCbCrSubsampleRatio422} {
+		// horizontal gradient on every plane
+		src := image.NewYCbCr(image.Rect(0, 0, 8, 6), ratio)
+		for y := range 6 {
+			for x := range 8 {
+				src.Y[src.YOffset(x, y)] = uint8(x * 32)
+				src.Cb[src.COffset(x, y)] = uint8(255 - x*32)
+				src.Cr[src.COffset(x, y)] = 128
+			}
+		}
+
+		output, err := NewImage(src, 16, 12, "bilinear", Options{}).InterpolateImage(context.Background(), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, ok := output.(*image.YCbCr)
+		if !ok {
+			t.Fatalf("%v: expected *image.YCbCr output but instead got %T", ratio, output)
+		}
+		if actual.SubsampleRatio != ratio || actual.Rect != image.Rect(0, 0, 16, 12) {
+			t.Fatalf("%v: expected a 16x12 image with the same subsampling but instead got %v %v", ratio, actual.SubsampleRatio, actual.Rect)
+		}
+
+		// the gradients have to survive in every plane
+		for y := range 12 {
+			for x := 1; x < 16; x++ {
+				if actual.Y[actual.YOffset(x, y)] < actual.Y[actual.YOffset(x-1, y)] {
+					t.Errorf("%v: expected Y to increase along x at [%d, %d]", ratio, x, y)
+				}
+				if actual.Cb[actual.COffset(x, y)] > actual.Cb[actual.COffset(x-1, y)] {
+					t.Errorf("%v: expected Cb to decrease along x at [%d, %d]", ratio, x, y)
+				}
+				if cr := actual.Cr[actual.COffset(x, y)]; cr != 128 {
+					t.Errorf("%v: expected Cr at [%d, %d] to stay 128 but instead got %d", ratio, x, y, cr)
+				}
+			}
+		}
+	}
+}
+
+func TestLinearYCbCr(t *testing.T) {
+	// 1px black and white checkerboard, as decoded from a jpeg
+	src := image.NewYCbCr(image.Rect(0, 0, 8, 8), image.YCbCrSubsampleRatio420)
+	for y := range 8 {
+		for x := range 8 {
+			if (x+y)%2 == 0 {
+				src.Y[src.YOffset(x, y)] = 255
+			}
+		}
+	}
+	for i := range src.Cb {
+		src.Cb[i], src.Cr[i] = 128, 128
+	}
+
+	// averages to the linear mid-grey of TestLinear, instead of blending Y
+	output, err := NewImage(src, 4, 4, "bilinear", Options{Linear: true}).InterpolateImage(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := range 4 {
+		for x := range 4 {
+			r, g, b, a := output.At(x, y).RGBA()
+			if absDiff(uint8(r>>8), 188) > 1 || r != g || r != b || a != 0xffff {
+				t.Errorf("expected RGBA at [%d, %d] to be [188±1, 188±1, 188±1, 255] but instead got %v", x, y, output.At(x, y))
+			}
+		}
+	}
+}
+
+func TestInterpolateContextCancel(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, method := range []string{"nearestneighbor", "bilinear", "bicubic"} {
+		interpolator := New(src, 64, 64, method)
+
+		for _, concurrency := range []bool{false, true} {
+			output, err := interpolator.InterpolateContext(ctx, concurrency)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("%s: expected context.Canceled but instead got %v", method, err)
+			}
+			if output != nil {
+				t.Errorf("%s: expected no output from a cancelled interpolation", method)
+			}
+		}
+	}
+}
+
+func TestObserver(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+
+	var stats []Stats
+	opts := Options{
+		Workers: 2,
+		Observer: func(s Stats) {
+			stats = append(stats, s)
+		},
+	}
+
+	interpolator := NewWithOptions(src, 8, 6, "bilinear", opts)
+	interpolator.Interpolate(false)
+	interpolator.Interpolate(true)
+
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 observations but instead got %d", len(stats))
+	}
+
+	for i, workers := range []int{1, 2} {
+		s := stats[i]
+		if s.Method != "bilinear" || s.Input != image.Pt(4, 3) || s.Output != image.Pt(8, 6) || s.Workers != workers || s.Err != nil {
+			t.Errorf("unexpected stats %+v", s)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	in := image.Pt(10, 6)
+
+	for _, c := range []struct {
+		method string
+		out    image.Point
+		ok     bool
+	}{
+		{"bicubic", image.Pt(3, 200), true},
+		{"seamcarve", image.Pt(8, 6), true},
+		{"xbr3x", image.Pt(30, 18), true},
+		{"xbr3x", image.Pt(20, 12), false},
+		{"lanczos", image.Pt(20, 12), false},
+		{"bilinear", image.Pt(0, 12), false},
+	} {
+		err := Validate(c.method, in, c.out)
+		if (err == nil) != c.ok {
+			t.Errorf("%s %v: expected ok to be %t but instead got %v", c.method, c.out, c.ok, err)
+		}
+	}
+
+	if err := Validate("xbr2x", in, in); !errors.Is(err, ErrScale) {
+		t.Errorf("expected ErrScale but instead got %v", err)
+	}
+
+	for _, c := range []struct {
+		method string
+		opts   Options
+		ok     bool
+	}{
+		{"bilinear", Options{}, true},
+		{"bilinear", Options{Edge: EdgeReflect}, true},
+		{"bilinear", Options{Edge: "mirror"}, false},
+		{"cubic", Options{B: 1. / 3, C: 1. / 3}, true},
+		{"cubic", Options{B: 0, C: 1.5}, false},
+		{"cubic", Options{B: math.NaN()}, false},
+		// presets don't read B and C
+		{"mitchell", Options{B: -1}, true},
+	} {
+		err := c.opts.Validate(c.method)
+		if (err == nil) != c.ok {
+			t.Errorf("%s %+v: expected ok to be %t but instead got %v", c.method, c.opts, c.ok, err)
+		}
+	}
+}
+
+func TestQuality(t *testing.T) {
+	f, err := os.Open("../assets/images/test-image.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	img, err := jpeg.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// halved with bilinear, then enlarged back with every method
+	src := ToNRGBA(img)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	small := New(src, w/2, h/2, "bilinear").Interpolate(true)
+
+	// slightly below the scores of each method, so that changes making them worse are caught
+	for _, c := range []struct {
+		method     string
+		psnr, ssim float64
+	}{
+		{"nearestneighbor", 23.2, 0.78},
+		{"bilinear", 22.9, 0.73},
+		{"bicubic", 23.4, 0.76},
+		{"mitchell", 23.1, 0.74},
+		{"bspline", 22.3, 0.69},
+		{"nedi", 23.4, 0.76},
+	} {
+		r, err := metrics.Compare(src, New(small, w, h, c.method).Interpolate(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%s: %v", c.method, r)
+
+		if r.PSNR < c.psnr || r.SSIM < c.ssim {
+			t.Errorf("%s: expected a PSNR of at least %.1fdB and an SSIM of at least %.2f but instead got %.2fdB and %.4f", c.method, c.psnr, c.ssim, r.PSNR, r.SSIM)
+		}
+	}
+}
+
+func BenchmarkScheduling(b *testing.B) {
+	src := image.NewNRGBA(image.Rect(0, 0, 500, 300))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i)
+	}
+
+	pool := parallel.NewPool(0)
+	defer pool.Close()
+
+	strategies := map[string]Options{
+		"flat":          {},
+		"flat 2":        {Workers: 2},
+		"row band":      {Partition: parallel.RowBand},
+		"row band 2":    {Workers: 2, Partition: parallel.RowBand},
+		"pool":          {Pool: pool},
+		"pool row band": {Pool: pool, Partition: parallel.RowBand},
+	}
+
+	for name, opts := range strategies {
+		b.Run(name, func(b *testing.B) {
+			interpolator := NewWithOptions(src, 1000, 600, "bicubic", opts)
+			for range b.N {
+				interpolator.Interpolate(true)
+			}
+		})
+	}
+}
+
 func absDiff(x, y uint8) uint8 {
 	if x >= y {
 		return x - y
@@ -0,0 +1,234 @@
+package interpolator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"gthub.com/obzva/image-resize/parallel"
+)
+
+// ErrScale is returned by pixel-art interpolators whose output is not their scale factor times their input
+var ErrScale = errors.New("output size is not an integer multiple of the input size")
+
+// scale factors of the pixel-art methods, see PixelArt
+var pixelArtScales = map[string]int{
+	"scale2x": 2,
+	"scale3x": 3,
+	"xbr2x":   2,
+	"xbr3x":   3,
+	"xbr4x":   4,
+}
+
+// PixelArt enlarges sprites by an integer factor, keeping their hard edges while smoothing diagonal ones
+// available methods are
+//   - scale2x and scale3x, EPX/AdvMAME rules copying the colors of neighbors into the corners of each pixel
+//   - xbr2x, xbr3x and xbr4x, blending the corners of each pixel into its neighbors where the weighted
+//     color distances of xBR (level 1) find an edge
+//
+// the output has to be exactly factor times the input in both directions, otherwise ErrScale is returned
+type PixelArt struct {
+	interpolation
+	input, output raster
+	factor        int
+	unit          float64 // scales color values to [0, 255]
+}
+
+// reports ErrScale when method is a pixel-art method that doesn't enlarge input into output
+func validatePixelArtScale(method string, input, output image.Point) error {
+	if factor, ok := pixelArtScales[method]; ok && output != input.Mul(factor) {
+		return fmt.Errorf("%w: %s enlarges %dx%d into %dx%d, not %dx%d", ErrScale, method, input.X, input.Y, input.X*factor, input.Y*factor, output.X, output.Y)
+	}
+
+	return nil
+}
+
+func newPixelArt(input, output raster, method string, opts Options) *PixelArt {
+	// alpha of white is the largest value of the channel type
+	unit := 255 / valueOf(input, color.White)[3]
+
+	pa := &PixelArt{input: input, output: output, factor: pixelArtScales[method], unit: unit}
+	pa.interpolation = newInterpolation(method, input.Bounds(), output.Bounds(), opts, pa.run)
+	return pa
+}
+
+// returns Y, U, V and alpha of the color values c, scaled to [0, 255]
+func (pa *PixelArt) yuva(c [4]float64) [4]float64 {
+	for i := range c {
+		c[i] *= pa.unit
+	}
+
+	if pa.input.channels() == 1 {
+		return [4]float64{c[0], 0, 0, c[3]}
+	}
+
+	return [4]float64{
+		0.299*c[0] + 0.587*c[1] + 0.114*c[2],
+		-0.169*c[0] - 0.331*c[1] + 0.5*c[2],
+		0.5*c[0] - 0.419*c[1] - 0.081*c[2],
+		c[3],
+	}
+}
+
+// weighted color distance of xBR
+func (pa *PixelArt) distance(a, b [4]float64) float64 {
+	ya, yb := pa.yuva(a), pa.yuva(b)
+
+	return 48*math.Abs(ya[0]-yb[0]) + 7*math.Abs(ya[1]-yb[1]) + 6*math.Abs(ya[2]-yb[2]) + 48*math.Abs(ya[3]-yb[3])
+}
+
+// blends the first n color channels and alpha of a towards b by w
+func mix(a, b [4]float64, n int, w float64) (c [4]float64) {
+	for i := range n {
+		c[i] = (1-w)*a[i] + w*b[i]
+	}
+	c[3] = (1-w)*a[3] + w*b[3]
+
+	return c
+}
+
+// calculates the color values of the sub-pixel (sX, sY) of the block the input pixel (x, y) is enlarged into
+func (pa *PixelArt) subpixel(x, y, sX, sY int) [4]float64 {
+	n := pa.factor
+
+	// reads the neighbors of (x, y), (dX, dY) is the offset from it
+	at := func(dX, dY int) [4]float64 {
+		return pa.input.at(x+dX, y+dY)
+	}
+
+	e := at(0, 0)
+
+	switch pa.method {
+	case "scale2x", "scale3x":
+		// A B C
+		// D E F
+		// G H I
+		a, b, c := at(-1, -1), at(0, -1), at(1, -1)
+		d, f := at(-1, 0), at(1, 0)
+		g, h, i := at(-1, 1), at(0, 1), at(1, 1)
+
+		// corners where two neighbors meet
+		tl := d == b && b != f && d != h
+		tr := b == f && b != d && f != h
+		bl := d == h && d != b && h != f
+		br := h == f && d != h && b != f
+
+		if n == 2 {
+			switch {
+			case sX == 0 && sY == 0 && tl:
+				return d
+			case sX == 1 && sY == 0 && tr:
+				return f
+			case sX == 0 && sY == 1 && bl:
+				return d
+			case sX == 1 && sY == 1 && br:
+				return f
+			}
+			return e
+		}
+
+		switch sY*3 + sX {
+		case 0:
+			if tl {
+				return d
+			}
+		case 1:
+			if (tl && e != c) || (tr && e != a) {
+				return b
+			}
+		case 2:
+			if tr {
+				return f
+			}
+		case 3:
+			if (tl && e != g) || (bl && e != a) {
+				return d
+			}
+		case 5:
+			if (tr && e != i) || (br && e != c) {
+				return f
+			}
+		case 6:
+			if bl {
+				return d
+			}
+		case 7:
+			if (bl && e != i) || (br && e != g) {
+				return h
+			}
+		case 8:
+			if br {
+				return f
+			}
+		}
+		return e
+	}
+
+	// xBR blends the corner nearest to the sub-pixel
+	// u and v are the distances of the sub-pixel center from the center of the block, in [0, 0.5)
+	cX := (float64(sX)+0.5)/float64(n) - 0.5
+	cY := (float64(sY)+0.5)/float64(n) - 0.5
+	u, v := math.Abs(cX), math.Abs(cY)
+
+	// weight of the neighbors, the edge cuts the corner diagonally halfway between its sides
+	w := max(0, min(1, (u+v-0.5)*float64(n)+0.5))
+	if w == 0 {
+		return e
+	}
+
+	// the neighbors towards the corner, mirrored so that it is always the bottom-right one
+	qX, qY := 1, 1
+	if cX < 0 {
+		qX = -1
+	}
+	if cY < 0 {
+		qY = -1
+	}
+	p := func(dX, dY int) [4]float64 {
+		return at(qX*dX, qY*dY)
+	}
+
+	// E F F4
+	// H I I4
+	// H5 I5
+	f, h := p(1, 0), p(0, 1)
+	ch := pa.input.channels()
+
+	// compares the color changes across the two diagonals of the corner
+	b, d := p(0, -1), p(-1, 0)
+	c, g, i := p(1, -1), p(-1, 1), p(1, 1)
+	f4, h5 := p(2, 0), p(0, 2)
+	i4, i5 := p(2, 1), p(1, 2)
+
+	along := pa.distance(e, c) + pa.distance(e, g) + pa.distance(i, f4) + pa.distance(i, h5) + 4*pa.distance(h, f)
+	across := pa.distance(h, d) + pa.distance(h, i5) + pa.distance(f, i4) + pa.distance(f, b) + 4*pa.distance(e, i)
+
+	if along < across && e != f && e != h {
+		if pa.distance(e, f) <= pa.distance(e, h) {
+			return mix(e, f, ch, w)
+		}
+		return mix(e, h, ch, w)
+	}
+	return e
+}
+
+func (pa *PixelArt) run(ctx context.Context, concurrency bool) (image.Image, error) {
+	iSize := pa.input.Bounds().Size()
+	oSize := pa.output.Bounds().Size()
+
+	if err := validatePixelArtScale(pa.method, iSize, oSize); err != nil {
+		return nil, err
+	}
+
+	err := parallel.RunPixels(ctx, concurrency, pa.opts.parallel(), oSize.X, oSize.Y, func(x, y int) {
+		pa.output.set(x, y, pa.subpixel(x/pa.factor, y/pa.factor, x%pa.factor, y%pa.factor))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pa.output.image(), nil
+}
@@ -0,0 +1,178 @@
+package interpolator
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// colors of the characters of sprite and ascii
+var spritePalette = map[byte]color.NRGBA{
+	'.': {0, 0, 0, 0},
+	'#': {0, 0, 0, 255},
+	'o': {255, 255, 255, 255},
+}
+
+// builds a sprite from rows of palette characters
+func sprite(rows ...string) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x := range len(row) {
+			img.SetNRGBA(x, y, spritePalette[row[x]])
+		}
+	}
+	return img
+}
+
+// draws img with palette characters, '+' stands for any blended color
+func ascii(img *image.NRGBA) string {
+	var sb strings.Builder
+	for y := range img.Bounds().Dy() {
+		for x := range img.Bounds().Dx() {
+			c := img.NRGBAAt(x, y)
+			char := byte('+')
+			for k, v := range spritePalette {
+				if c == v || (c.A == 0 && v.A == 0) {
+					char = k
+				}
+			}
+			sb.WriteByte(char)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func TestPixelArt(t *testing.T) {
+	// diagonal line ending in a white pixel
+	src := sprite(
+		"....",
+		".#..",
+		"..#.",
+		"...o",
+	)
+
+	// '+' marks blended colors
+	golden := map[string][]string{
+		"scale2x": {
+			"........",
+			"........",
+			"..##....",
+			"..###...",
+			"...###..",
+			"....##..",
+			".......o",
+			"......oo",
+		},
+		"scale3x": {
+			"............",
+			"............",
+			"............",
+			"...###......",
+			"...###......",
+			"...####.....",
+			".....####...",
+			"......###...",
+			"......###...",
+			"...........o",
+			"..........oo",
+			".........ooo",
+		},
+		"xbr2x": {
+			"........",
+			"........",
+			"..++....",
+			"..+#+...",
+			"...+#+..",
+			"....++..",
+			"......+o",
+			"......oo",
+		},
+		"xbr4x": {
+			"................",
+			"................",
+			"................",
+			"................",
+			".....++.........",
+			"....+##+........",
+			"....+###+.......",
+			".....+###+......",
+			"......+###+.....",
+			".......+###+....",
+			"........+##+....",
+			".........++.....",
+			".............+oo",
+			"............+ooo",
+			"............oooo",
+			"............oooo",
+		},
+	}
+
+	for method, rows := range golden {
+		f := pixelArtScales[method]
+		actual := ascii(New(src, 4*f, 4*f, method).Interpolate(true))
+		expected := strings.Join(rows, "\n") + "\n"
+
+		if actual != expected {
+			t.Errorf("%s: expected\n%s\nbut instead got\n%s", method, expected, actual)
+		}
+	}
+}
+
+func TestPixelArtFlat(t *testing.T) {
+	// wrapped checkerboards have no edges to smooth, scale2x and scale3x keep them as nearest neighbor does
+	src := sprite(
+		"#o#o",
+		"o#o#",
+		"#o#o",
+		"o#o#",
+	)
+
+	for _, method := range []string{"scale2x", "scale3x"} {
+		f := pixelArtScales[method]
+		expected := ascii(New(src, 4*f, 4*f, "nearestneighbor").Interpolate(false))
+		if actual := ascii(NewWithOptions(src, 4*f, 4*f, method, Options{Edge: EdgeWrap}).Interpolate(false)); actual != expected {
+			t.Errorf("%s: expected\n%s\nbut instead got\n%s", method, expected, actual)
+		}
+	}
+
+	// flat images stay flat
+	flat := sprite("oo", "oo")
+	for method, f := range pixelArtScales {
+		if actual := ascii(New(flat, 2*f, 2*f, method).Interpolate(false)); strings.Trim(actual, "o\n") != "" {
+			t.Errorf("%s: expected a white output but instead got\n%s", method, actual)
+		}
+	}
+}
+
+func TestPixelArtScale(t *testing.T) {
+	src := sprite("#o", "o#")
+
+	for method, f := range pixelArtScales {
+		for _, size := range []image.Point{{2*f + 1, 2 * f}, {2 * f, 2*f - 1}, {3, 3}} {
+			_, err := NewImage(src, size.X, size.Y, method, Options{}).InterpolateImage(context.Background(), false)
+			if !errors.Is(err, ErrScale) {
+				t.Errorf("%s: expected ErrScale for a %v output but instead got %v", method, size, err)
+			}
+		}
+	}
+
+	// other kinds of images are enlarged too
+	gray := image.NewGray(image.Rect(0, 0, 2, 2))
+	gray.Pix = []uint8{0, 255, 255, 0}
+	output, err := NewImage(gray, 4, 4, "scale2x", Options{}).InterpolateImage(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := output.(*image.Gray); !ok {
+		t.Errorf("expected *image.Gray to be kept but instead got %T", output)
+	}
+
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 2, 2), image.YCbCrSubsampleRatio420)
+	if _, err := NewImage(ycbcr, 6, 6, "xbr3x", Options{}).InterpolateImage(context.Background(), false); err != nil {
+		t.Errorf("expected subsampled YCbCr images to be enlarged but instead got %v", err)
+	}
+}
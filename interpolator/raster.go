@@ -0,0 +1,249 @@
+package interpolator
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// raster is an image whose pixels interpolators read and write as color values in the working space
+// of the interpolation: index 0 to 3 are R, G, B and A, premultiplied by alpha,
+// in linear light when linear is set, and scaled to [0, max value of the channel type]
+type raster interface {
+	Bounds() image.Rectangle
+	channels() int // number of color channels in use, from index 0
+	at(x, y int) [4]float64
+	set(x, y int, c [4]float64) // values out of range are clamped
+	image() image.Image
+}
+
+// returns a raster reading src
+// *image.NRGBA, *image.NRGBA64 and *image.Gray are used in place, anything else is converted
+// into *image.NRGBA64 when it has more than 8 bits per channel, and into *image.NRGBA otherwise
+// images whose bounds don't start at (0, 0) are always converted
+func newRaster(src image.Image, linear bool) raster {
+	if src.Bounds().Min == (image.Point{}) {
+		switch src := src.(type) {
+		case *image.NRGBA:
+			return &nrgbaRaster{src, linear}
+		case *image.NRGBA64:
+			return &nrgba64Raster{src, linear}
+		case *image.Gray:
+			return newGrayRaster(src, linear)
+		case *image.YCbCr:
+			if src.SubsampleRatio == image.YCbCrSubsampleRatio444 {
+				return &ycbcrRaster{src}
+			}
+		}
+	}
+
+	b := src.Bounds()
+	if Is16Bit(src.ColorModel()) {
+		dst := image.NewNRGBA64(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+		return &nrgba64Raster{dst, linear}
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return &nrgbaRaster{dst, linear}
+}
+
+// returns an empty w x h raster of the same kind as r
+func newRasterLike(r raster, w, h int, linear bool) raster {
+	switch r.(type) {
+	case *nrgba64Raster:
+		return &nrgba64Raster{image.NewNRGBA64(image.Rect(0, 0, w, h)), linear}
+	case *planeRaster:
+		return newGrayRaster(image.NewGray(image.Rect(0, 0, w, h)), linear)
+	case *ycbcrRaster:
+		return &ycbcrRaster{image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio444)}
+	}
+	return &nrgbaRaster{image.NewNRGBA(image.Rect(0, 0, w, h)), linear}
+}
+
+// Is16Bit reports whether m is one of the standard color models with 16 bits per channel
+func Is16Bit(m color.Model) bool {
+	return m == color.RGBA64Model || m == color.NRGBA64Model || m == color.Gray16Model
+}
+
+type nrgbaRaster struct {
+	img    *image.NRGBA
+	linear bool
+}
+
+func (r *nrgbaRaster) Bounds() image.Rectangle {
+	return r.img.Bounds()
+}
+
+func (r *nrgbaRaster) channels() int {
+	return 4
+}
+
+func (r *nrgbaRaster) at(x, y int) [4]float64 {
+	c := r.img.NRGBAAt(x, y)
+
+	var v [4]float64
+	if r.linear {
+		lut := toLinear8()
+		v = [4]float64{lut[c.R], lut[c.G], lut[c.B], float64(c.A)}
+	} else {
+		v = [4]float64{float64(c.R), float64(c.G), float64(c.B), float64(c.A)}
+	}
+
+	return premultiply(v, 255)
+}
+
+func (r *nrgbaRaster) set(x, y int, v [4]float64) {
+	v = unpremultiply(v, 255)
+
+	if r.linear {
+		for i := range 3 {
+			v[i] = float64(encodeSRGB(v[i], 255)) / 257
+		}
+	}
+
+	r.img.SetNRGBA(x, y, color.NRGBA{clamp[uint8](v[0]), clamp[uint8](v[1]), clamp[uint8](v[2]), clamp[uint8](v[3])})
+}
+
+func (r *nrgbaRaster) image() image.Image {
+	return r.img
+}
+
+type nrgba64Raster struct {
+	img    *image.NRGBA64
+	linear bool
+}
+
+func (r *nrgba64Raster) Bounds() image.Rectangle {
+	return r.img.Bounds()
+}
+
+func (r *nrgba64Raster) channels() int {
+	return 4
+}
+
+func (r *nrgba64Raster) at(x, y int) [4]float64 {
+	c := r.img.NRGBA64At(x, y)
+
+	var v [4]float64
+	if r.linear {
+		lut := toLinear16()
+		v = [4]float64{lut[c.R], lut[c.G], lut[c.B], float64(c.A)}
+	} else {
+		v = [4]float64{float64(c.R), float64(c.G), float64(c.B), float64(c.A)}
+	}
+
+	return premultiply(v, 65535)
+}
+
+func (r *nrgba64Raster) set(x, y int, v [4]float64) {
+	v = unpremultiply(v, 65535)
+
+	if r.linear {
+		for i := range 3 {
+			v[i] = float64(encodeSRGB(v[i], 65535))
+		}
+	}
+
+	r.img.SetNRGBA64(x, y, color.NRGBA64{clamp[uint16](v[0]), clamp[uint16](v[1]), clamp[uint16](v[2]), clamp[uint16](v[3])})
+}
+
+func (r *nrgba64Raster) image() image.Image {
+	return r.img
+}
+
+// planeRaster reads and writes a single 8-bit channel, such as the pixels of an *image.Gray
+// or one of the planes of an *image.YCbCr
+// only index 0 of the color values is used, alpha is always opaque
+type planeRaster struct {
+	pix    []uint8
+	stride int
+	rect   image.Rectangle
+	linear bool
+	img    image.Image // image the plane belongs to
+}
+
+func newGrayRaster(img *image.Gray, linear bool) *planeRaster {
+	return &planeRaster{img.Pix, img.Stride, img.Rect, linear, img}
+}
+
+func (r *planeRaster) Bounds() image.Rectangle {
+	return r.rect
+}
+
+func (r *planeRaster) channels() int {
+	return 1
+}
+
+func (r *planeRaster) at(x, y int) [4]float64 {
+	// out of bounds reads get zero, same as (*image.NRGBA).NRGBAAt
+	if !(image.Point{x, y}.In(r.rect)) {
+		return [4]float64{}
+	}
+
+	v := r.pix[y*r.stride+x]
+	if r.linear {
+		return [4]float64{toLinear8()[v], 0, 0, 255}
+	}
+	return [4]float64{float64(v), 0, 0, 255}
+}
+
+func (r *planeRaster) set(x, y int, v [4]float64) {
+	if r.linear {
+		v[0] = float64(encodeSRGB(v[0], 255)) / 257
+	}
+	r.pix[y*r.stride+x] = clamp[uint8](v[0])
+}
+
+func (r *planeRaster) image() image.Image {
+	return r.img
+}
+
+// ycbcrRaster reads and writes an *image.YCbCr without chroma subsampling,
+// index 0 to 2 of the color values are Y, Cb and Cr, alpha is always opaque
+// subsampled images are interpolated plane by plane instead, see YCbCr
+type ycbcrRaster struct {
+	img *image.YCbCr
+}
+
+func (r *ycbcrRaster) Bounds() image.Rectangle {
+	return r.img.Rect
+}
+
+func (r *ycbcrRaster) channels() int {
+	return 3
+}
+
+func (r *ycbcrRaster) at(x, y int) [4]float64 {
+	// out of bounds reads get zero, same as (*image.NRGBA).NRGBAAt
+	if !(image.Point{x, y}.In(r.img.Rect)) {
+		return [4]float64{}
+	}
+
+	// the chroma planes have the same layout as the luma plane with 4:4:4
+	i := y*r.img.YStride + x
+	return [4]float64{float64(r.img.Y[i]), float64(r.img.Cb[i]), float64(r.img.Cr[i]), 255}
+}
+
+func (r *ycbcrRaster) set(x, y int, v [4]float64) {
+	i := y*r.img.YStride + x
+	r.img.Y[i] = clamp[uint8](v[0])
+	r.img.Cb[i] = clamp[uint8](v[1])
+	r.img.Cr[i] = clamp[uint8](v[2])
+}
+
+func (r *ycbcrRaster) image() image.Image {
+	return r.img
+}
+
+// ToNRGBA returns img as *image.NRGBA, converting it when it is of another type
+func ToNRGBA(img image.Image) *image.NRGBA {
+	if img, ok := img.(*image.NRGBA); ok {
+		return img
+	}
+
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,324 @@
+package interpolator
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+
+	"gthub.com/obzva/image-resize/parallel"
+)
+
+// energy of the pixels marked by the masks, far beyond any change of color
+const maskEnergy = 1e12
+
+// Masks mark pixels of the source of a SeamCarver wherever they are neither black nor transparent, whatever their color
+// colors whose channels all stay below maskBlack once alpha is applied count as black, so that the noise of lossy formats
+// doesn't mark pixels
+// both are optional and have to be the size of the source
+type Masks struct {
+	Protect image.Image // seams avoid the marked pixels, so that they are neither removed nor stretched
+	Remove  image.Image // the marked pixels are carved away first, then the image is resized to its target size
+}
+
+// SeamCarver resizes images by removing or inserting seams, connected paths of one pixel per row (or column)
+// running through the pixels whose colors change the least, so that the content that matters keeps its shape
+// for more detail, please refer to https://en.wikipedia.org/wiki/Seam_carving
+// widths are carved before heights, progress is reported as seams done out of all seams
+type SeamCarver struct {
+	interpolation
+	input, output raster
+	masks         Masks
+}
+
+// initialize SeamCarver resizing src into w x h
+// same as NewImage with the "seamcarve" method, plus masks
+func NewSeamCarver(src image.Image, w, h int, masks Masks, opts Options) Interpolator {
+	sc := NewImage(src, w, h, "seamcarve", opts).(*SeamCarver)
+	sc.masks = masks
+
+	return sc
+}
+
+// carving is an image in the middle of being carved
+type carving struct {
+	pix  [][4]float64
+	mark []float64 // energy added by the masks
+	w, h int
+	n    int // number of color channels in use
+}
+
+// returns c with its rows and columns swapped, so that horizontal seams can be carved as vertical ones
+func (c *carving) transpose() *carving {
+	t := &carving{pix: make([][4]float64, len(c.pix)), mark: make([]float64, len(c.mark)), w: c.h, h: c.w, n: c.n}
+	for y := range c.h {
+		for x := range c.w {
+			t.pix[x*t.w+y] = c.pix[y*c.w+x]
+			t.mark[x*t.w+y] = c.mark[y*c.w+x]
+		}
+	}
+
+	return t
+}
+
+// calculates the energy of every pixel of c, how much the colors change around it, plus the energy of the masks
+func (c *carving) energy(ctx context.Context, concurrency bool, config parallel.Config) ([]float64, error) {
+	e := make([]float64, c.w*c.h)
+
+	err := parallel.RunPixels(ctx, concurrency, config, c.w, c.h, func(x, y int) {
+		i := y*c.w + x
+
+		// central differences, one-sided at the borders
+		l, r := c.pix[y*c.w+max(x-1, 0)], c.pix[y*c.w+min(x+1, c.w-1)]
+		t, b := c.pix[max(y-1, 0)*c.w+x], c.pix[min(y+1, c.h-1)*c.w+x]
+
+		var v float64
+		for ch := range c.n {
+			v += math.Abs(r[ch]-l[ch]) + math.Abs(b[ch]-t[ch])
+		}
+		e[i] = v + c.mark[i]
+	})
+
+	return e, err
+}
+
+// returns the x of every row of the vertical seam with the least energy
+func (c *carving) seam(e []float64) []int {
+	// least energy of the seams ending at each pixel, from the top row down
+	cost := make([]float64, len(e))
+	copy(cost[:c.w], e[:c.w])
+
+	for y := 1; y < c.h; y++ {
+		for x := range c.w {
+			prev := cost[(y-1)*c.w+x]
+			if x > 0 {
+				prev = math.Min(prev, cost[(y-1)*c.w+x-1])
+			}
+			if x < c.w-1 {
+				prev = math.Min(prev, cost[(y-1)*c.w+x+1])
+			}
+			cost[y*c.w+x] = e[y*c.w+x] + prev
+		}
+	}
+
+	// follow the cheapest seam back up from the bottom row
+	seam := make([]int, c.h)
+	last := (c.h - 1) * c.w
+	for x := 1; x < c.w; x++ {
+		if cost[last+x] < cost[last+seam[c.h-1]] {
+			seam[c.h-1] = x
+		}
+	}
+
+	for y := c.h - 2; y >= 0; y-- {
+		best := seam[y+1]
+		for _, x := range []int{seam[y+1] - 1, seam[y+1] + 1} {
+			if x >= 0 && x < c.w && cost[y*c.w+x] < cost[y*c.w+best] {
+				best = x
+			}
+		}
+		seam[y] = best
+	}
+
+	return seam
+}
+
+// removes the pixels of a vertical seam from c, and from the original x coordinates of its pixels, if any
+func (c *carving) remove(seam []int, origins []int) {
+	w := c.w - 1
+
+	for y, sX := range seam {
+		// rows shift left by one more pixel for every row above
+		copy(c.pix[y*w:], c.pix[y*c.w:y*c.w+sX])
+		copy(c.pix[y*w+sX:], c.pix[y*c.w+sX+1:(y+1)*c.w])
+		copy(c.mark[y*w:], c.mark[y*c.w:y*c.w+sX])
+		copy(c.mark[y*w+sX:], c.mark[y*c.w+sX+1:(y+1)*c.w])
+
+		if origins != nil {
+			copy(origins[y*w:], origins[y*c.w:y*c.w+sX])
+			copy(origins[y*w+sX:], origins[y*c.w+sX+1:(y+1)*c.w])
+		}
+	}
+
+	c.w = w
+	c.pix = c.pix[:w*c.h]
+	c.mark = c.mark[:w*c.h]
+}
+
+// returns the largest number of pixels marked for removal in a row of c,
+// the least number of seams left to carve them away
+func (c *carving) marked() int {
+	var most int
+	for y := range c.h {
+		var n int
+		for _, m := range c.mark[y*c.w : (y+1)*c.w] {
+			if m < 0 {
+				n++
+			}
+		}
+		most = max(most, n)
+	}
+	return most
+}
+
+// carves c down or up to width w, one seam at a time, reporting every seam to done
+func (c *carving) carve(ctx context.Context, concurrency bool, config parallel.Config, w int, done func()) error {
+	for c.w > w {
+		e, err := c.energy(ctx, concurrency, config)
+		if err != nil {
+			return err
+		}
+		c.remove(c.seam(e), nil)
+		done()
+	}
+
+	// inserts seams in rounds, so that no round duplicates more than half of the pixels
+	for c.w < w {
+		k := min(w-c.w, max(c.w/2, 1))
+
+		// find the k seams of least energy by carving them away from a copy,
+		// and remember where their pixels were in c
+		tmp := &carving{pix: append([][4]float64(nil), c.pix...), mark: append([]float64(nil), c.mark...), w: c.w, h: c.h, n: c.n}
+		origins := make([]int, c.w*c.h)
+		for i := range origins {
+			origins[i] = i % c.w
+		}
+
+		duplicated := make([]bool, c.w*c.h)
+		for range k {
+			e, err := tmp.energy(ctx, concurrency, config)
+			if err != nil {
+				return err
+			}
+
+			seam := tmp.seam(e)
+			for y, sX := range seam {
+				duplicated[y*c.w+origins[y*tmp.w+sX]] = true
+			}
+			tmp.remove(seam, origins)
+		}
+
+		// every row gets k new pixels, each one the average of a seam pixel and its right neighbor
+		nW := c.w + k
+		pix := make([][4]float64, nW*c.h)
+		mark := make([]float64, nW*c.h)
+		for y := range c.h {
+			nX := y * nW
+			for x := range c.w {
+				i := y*c.w + x
+				pix[nX], mark[nX] = c.pix[i], c.mark[i]
+				nX++
+
+				if duplicated[i] {
+					r := c.pix[y*c.w+min(x+1, c.w-1)]
+					for ch := range 4 {
+						pix[nX][ch] = (c.pix[i][ch] + r[ch]) / 2
+					}
+					mark[nX] = c.mark[i]
+					nX++
+				}
+			}
+		}
+
+		c.pix, c.mark, c.w = pix, mark, nW
+		for range k {
+			done()
+		}
+	}
+
+	return nil
+}
+
+// 16-bit value from which mask channels are not black, 16 of 255
+const maskBlack = 16 * 0x101
+
+// returns the mask energy of every pixel of a w x h source
+func maskEnergies(masks Masks, w, h int) ([]float64, error) {
+	mark := make([]float64, w*h)
+
+	for _, m := range []struct {
+		img    image.Image
+		energy float64
+	}{{masks.Protect, maskEnergy}, {masks.Remove, -maskEnergy}} {
+		if m.img == nil {
+			continue
+		}
+
+		b := m.img.Bounds()
+		if b.Dx() != w || b.Dy() != h {
+			return nil, fmt.Errorf("mask of %dx%d doesn't match the %dx%d input", b.Dx(), b.Dy(), w, h)
+		}
+
+		for y := range h {
+			for x := range w {
+				// premultiplied, so that transparent pixels are black
+				if r, g, bl, _ := m.img.At(b.Min.X+x, b.Min.Y+y).RGBA(); max(r, g, bl) >= maskBlack {
+					mark[y*w+x] = m.energy
+				}
+			}
+		}
+	}
+
+	return mark, nil
+}
+
+func (sc *SeamCarver) run(ctx context.Context, concurrency bool) (image.Image, error) {
+	iW, iH := sc.input.Bounds().Dx(), sc.input.Bounds().Dy()
+	oW, oH := sc.output.Bounds().Dx(), sc.output.Bounds().Dy()
+
+	c := &carving{pix: make([][4]float64, iW*iH), w: iW, h: iH, n: sc.input.channels()}
+	for y := range iH {
+		for x := range iW {
+			c.pix[y*iW+x] = sc.input.at(x, y)
+		}
+	}
+	var err error
+	if c.mark, err = maskEnergies(sc.masks, iW, iH); err != nil {
+		return nil, err
+	}
+
+	// energy maps are split between workers, progress is reported per seam instead of per row
+	config := sc.opts.parallel()
+	config.Progress = nil
+
+	// seams are throttled like rows, so that wide carves don't flood the callback
+	var report func(done, total int)
+	if sc.opts.Progress != nil {
+		report = parallel.Throttle(sc.opts.Progress)
+	}
+
+	var seams, total int
+	done := func() {
+		seams++
+		if report != nil {
+			report(seams, total)
+		}
+	}
+
+	// objects marked for removal go first, through vertical seams
+	for n := c.marked(); n > 0 && c.w > 1; n = c.marked() {
+		total = seams + n + abs(c.w-n-oW) + abs(c.h-oH)
+		if err = c.carve(ctx, concurrency, config, c.w-1, done); err != nil {
+			return nil, err
+		}
+	}
+
+	total = seams + abs(c.w-oW) + abs(c.h-oH)
+	if err = c.carve(ctx, concurrency, config, oW, done); err != nil {
+		return nil, err
+	}
+
+	c = c.transpose()
+	if err = c.carve(ctx, concurrency, config, oH, done); err != nil {
+		return nil, err
+	}
+	c = c.transpose()
+
+	for y := range oH {
+		for x := range oW {
+			sc.output.set(x, y, c.pix[y*oW+x])
+		}
+	}
+
+	return sc.output.image(), nil
+}
@@ -0,0 +1,179 @@
+package interpolator
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// 40x20 gray image with a dark block on the left, a black stripe and a red block
+func carvingSource() *image.NRGBA {
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for y := range 20 {
+		for x := range 40 {
+			c := color.NRGBA{128, 128, 128, 255}
+			switch {
+			case x < 10:
+				c = color.NRGBA{100, 100, 100, 255}
+			case x >= 18 && x < 21:
+				c = color.NRGBA{0, 0, 0, 255}
+			case x >= 30 && x < 34 && y >= 5 && y < 15:
+				c = color.NRGBA{255, 0, 0, 255}
+			}
+			src.SetNRGBA(x, y, c)
+		}
+	}
+	return src
+}
+
+// returns the number of pixels of color c in every row of img
+func countRows(img *image.NRGBA, c color.NRGBA) []int {
+	counts := make([]int, img.Bounds().Dy())
+	for y := range counts {
+		for x := range img.Bounds().Dx() {
+			if img.NRGBAAt(x, y) == c {
+				counts[y]++
+			}
+		}
+	}
+	return counts
+}
+
+func TestSeamCarver(t *testing.T) {
+	src := carvingSource()
+	black := color.NRGBA{0, 0, 0, 255}
+	dark := color.NRGBA{100, 100, 100, 255}
+	red := color.NRGBA{255, 0, 0, 255}
+
+	for _, size := range []image.Point{{30, 20}, {52, 20}, {30, 14}, {40, 26}} {
+		for _, concurrency := range []bool{false, true} {
+			actual := NewWithOptions(src, size.X, size.Y, "seamcarve", Options{Workers: 3}).Interpolate(concurrency)
+
+			if actual.Bounds().Size() != size {
+				t.Fatalf("%v: expected %v output but instead got %v", size, size, actual.Bounds().Size())
+			}
+
+			// the stripe runs through all rows, so it is neither carved nor stretched horizontally
+			for y, n := range countRows(actual, black) {
+				if n != 3 {
+					t.Errorf("%v (concurrency: %t): expected 3 black pixels in row %d but instead got %d", size, concurrency, y, n)
+					break
+				}
+			}
+		}
+	}
+
+	// seams run through the flat dark block first, unless it is protected
+	protect := image.NewGray(src.Bounds())
+	for y := range 20 {
+		for x := range 10 {
+			protect.SetGray(x, y, color.Gray{255})
+		}
+	}
+
+	carved := NewWithOptions(src, 30, 20, "seamcarve", Options{}).Interpolate(true)
+	if n := countRows(carved, dark)[0]; n >= 10 {
+		t.Errorf("expected the dark block to be carved but instead got %d dark pixels in the first row", n)
+	}
+
+	protected := NewSeamCarver(src, 30, 20, Masks{Protect: protect}, Options{}).Interpolate(true)
+	for y, n := range countRows(protected, dark) {
+		if n != 10 {
+			t.Errorf("expected 10 dark pixels in row %d of the protected output but instead got %d", y, n)
+			break
+		}
+	}
+
+	// the red block is carved away, then the image gets its size back
+	remove := image.NewGray(src.Bounds())
+	for y := 5; y < 15; y++ {
+		for x := 30; x < 34; x++ {
+			remove.SetGray(x, y, color.Gray{255})
+		}
+	}
+
+	removed := NewSeamCarver(src, 40, 20, Masks{Protect: protect, Remove: remove}, Options{}).Interpolate(true)
+	if removed.Bounds().Size() != image.Pt(40, 20) {
+		t.Fatalf("expected 40x20 output but instead got %v", removed.Bounds().Size())
+	}
+	for y, n := range countRows(removed, red) {
+		if n != 0 {
+			t.Errorf("expected no red pixels left in row %d but instead got %d", y, n)
+			break
+		}
+	}
+	for y, n := range countRows(removed, dark) {
+		if n != 10 {
+			t.Errorf("expected 10 dark pixels in row %d after the removal but instead got %d", y, n)
+			break
+		}
+	}
+
+	// masks of any color and opacity mark pixels, nearly black ones don't
+	protect = image.NewGray(src.Bounds())
+	colored := image.NewNRGBA(src.Bounds())
+	for y := range 20 {
+		for x := range 40 {
+			protect.SetGray(x, y, color.Gray{10})
+			if x < 10 {
+				colored.SetNRGBA(x, y, color.NRGBA{60, 0, 0, 255})
+			} else if x >= 30 && x < 34 && y >= 5 && y < 15 {
+				colored.SetNRGBA(x, y, color.NRGBA{255, 255, 255, 128})
+			}
+		}
+	}
+
+	if n := countRows(NewSeamCarver(src, 30, 20, Masks{Protect: protect}, Options{}).Interpolate(true), dark)[0]; n >= 10 {
+		t.Errorf("expected a nearly black mask to mark nothing but instead got %d dark pixels in the first row", n)
+	}
+	for y, n := range countRows(NewSeamCarver(src, 30, 20, Masks{Protect: colored}, Options{}).Interpolate(true), dark) {
+		if n != 10 {
+			t.Errorf("expected 10 dark pixels in row %d of the output protected by a dark red mask but instead got %d", y, n)
+			break
+		}
+	}
+
+	// the half-transparent part of the mask removes the red block
+	onlyRemove := image.NewNRGBA(src.Bounds())
+	for y := range 20 {
+		for x := range 40 {
+			if c := colored.NRGBAAt(x, y); c.A == 128 {
+				onlyRemove.SetNRGBA(x, y, c)
+			}
+		}
+	}
+	for y, n := range countRows(NewSeamCarver(src, 40, 20, Masks{Remove: onlyRemove}, Options{}).Interpolate(true), red) {
+		if n != 0 {
+			t.Errorf("expected a half-transparent mask to remove the red pixels of row %d but instead got %d", y, n)
+			break
+		}
+	}
+
+	// masks have to match the source
+	_, err := NewSeamCarver(src, 30, 20, Masks{Protect: image.NewGray(image.Rect(0, 0, 4, 4))}, Options{}).InterpolateContext(context.Background(), false)
+	if err == nil {
+		t.Error("expected an error for a mask of the wrong size but instead got nil")
+	}
+}
+
+func TestSeamCarverProgress(t *testing.T) {
+	// 300 seams, far more than one per percent
+	src := image.NewNRGBA(image.Rect(0, 0, 310, 4))
+
+	var reports []int
+	opts := Options{Progress: func(done, total int) {
+		if total != 300 {
+			t.Errorf("expected total to be 300 but instead got %d", total)
+		}
+		reports = append(reports, done)
+	}}
+	NewWithOptions(src, 10, 4, "seamcarve", opts).Interpolate(false)
+
+	if len(reports) == 0 || len(reports) > 101 {
+		t.Fatalf("expected between 1 and 101 reports but instead got %d", len(reports))
+	}
+	if last := reports[len(reports)-1]; last != 300 {
+		t.Errorf("expected the last report to be 300 but instead got %d", last)
+	}
+}
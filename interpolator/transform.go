@@ -0,0 +1,226 @@
+package interpolator
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+// Transform maps coordinates of the output image onto coordinates of the input image
+// coordinates are continuous, integer values are the centers of pixels
+// points that have no counterpart in the input, such as the far side of a perspective horizon, map to NaN
+type Transform interface {
+	Map(x, y float64) (tX float64, tY float64)
+}
+
+// Scale is the Transform of a resize, X and Y are (output width / input width) and (output height / input height)
+// the centers of the input and output images are aligned, see getOffset
+type Scale struct {
+	X, Y float64
+}
+
+func (s Scale) Map(x, y float64) (tX float64, tY float64) {
+	return x/s.X - getOffset(s.X), y/s.Y - getOffset(s.Y)
+}
+
+// returns the Scale that resizes input into output
+func scaleOf(input, output raster) Scale {
+	iW := input.Bounds().Dx()
+	iH := input.Bounds().Dy()
+
+	oW := output.Bounds().Dx()
+	oH := output.Bounds().Dy()
+
+	return Scale{float64(oW) / float64(iW), float64(oH) / float64(iH)}
+}
+
+// same as Scale, but aligns the top-left corners of the pixels instead of their centers,
+// which is how nearest neighbor has always picked pixels
+type cornerScale Scale
+
+func (s cornerScale) Map(x, y float64) (tX float64, tY float64) {
+	return x/s.X - 0.5, y/s.Y - 0.5
+}
+
+// Affine is a 2x3 matrix mapping (x, y) onto (a*x + b*y + c, d*x + e*y + f)
+// stored in row-major order: {a, b, c, d, e, f}
+type Affine [6]float64
+
+// Identity maps every point onto itself
+var Identity = Affine{1, 0, 0, 0, 1, 0}
+
+func (m Affine) Map(x, y float64) (tX float64, tY float64) {
+	return m[0]*x + m[1]*y + m[2], m[3]*x + m[4]*y + m[5]
+}
+
+// Mul returns the Affine that applies n first and m after
+func (m Affine) Mul(n Affine) Affine {
+	return Affine{
+		m[0]*n[0] + m[1]*n[3], m[0]*n[1] + m[1]*n[4], m[0]*n[2] + m[1]*n[5] + m[2],
+		m[3]*n[0] + m[4]*n[3], m[3]*n[1] + m[4]*n[4], m[3]*n[2] + m[4]*n[5] + m[5],
+	}
+}
+
+// Invert returns the Affine that undoes m
+func (m Affine) Invert() (Affine, error) {
+	det := m[0]*m[4] - m[1]*m[3]
+	if det == 0 {
+		return Affine{}, errors.New("affine transform is not invertible")
+	}
+
+	return Affine{
+		m[4] / det, -m[1] / det, (m[1]*m[5] - m[4]*m[2]) / det,
+		-m[3] / det, m[0] / det, (m[3]*m[2] - m[0]*m[5]) / det,
+	}, nil
+}
+
+// Translate returns the Affine moving points by (tx, ty)
+func Translate(tx, ty float64) Affine {
+	return Affine{1, 0, tx, 0, 1, ty}
+}
+
+// RotationAffine returns the Affine rotating points clockwise by degrees around the origin
+// (clockwise on screen, where the y-axis points down)
+func RotationAffine(degrees float64) Affine {
+	var sin, cos float64
+
+	// exact values for multiples of 90 degrees, so that quarter turns stay lossless
+	if q := math.Mod(degrees, 90); q == 0 {
+		turns := int(math.Mod(degrees/90, 4)+4) % 4
+		sin = [4]float64{0, 1, 0, -1}[turns]
+		cos = [4]float64{1, 0, -1, 0}[turns]
+	} else {
+		sin, cos = math.Sincos(degrees * math.Pi / 180)
+	}
+
+	return Affine{cos, -sin, 0, sin, cos, 0}
+}
+
+// Shear returns the Affine shearing points by sx along the x-axis and sy along the y-axis
+func Shear(sx, sy float64) Affine {
+	return Affine{1, sx, 0, sy, 1, 0}
+}
+
+// Perspective is a 3x3 matrix (homography) mapping (x, y) onto
+// ((a*x + b*y + c) / (g*x + h*y + i), (d*x + e*y + f) / (g*x + h*y + i))
+// stored in row-major order: {a, b, c, d, e, f, g, h, i}
+type Perspective [9]float64
+
+func (m Perspective) Map(x, y float64) (tX float64, tY float64) {
+	w := m[6]*x + m[7]*y + m[8]
+	if w <= 0 {
+		// beyond the horizon
+		return math.NaN(), math.NaN()
+	}
+
+	return (m[0]*x + m[1]*y + m[2]) / w, (m[3]*x + m[4]*y + m[5]) / w
+}
+
+// Invert returns the Perspective that undoes m
+func (m Perspective) Invert() (Perspective, error) {
+	// adjugate divided by determinant
+	inv := Perspective{
+		m[4]*m[8] - m[5]*m[7], m[2]*m[7] - m[1]*m[8], m[1]*m[5] - m[2]*m[4],
+		m[5]*m[6] - m[3]*m[8], m[0]*m[8] - m[2]*m[6], m[2]*m[3] - m[0]*m[5],
+		m[3]*m[7] - m[4]*m[6], m[1]*m[6] - m[0]*m[7], m[0]*m[4] - m[1]*m[3],
+	}
+
+	det := m[0]*inv[0] + m[1]*inv[3] + m[2]*inv[6]
+	if det == 0 {
+		return Perspective{}, errors.New("perspective transform is not invertible")
+	}
+
+	for i := range inv {
+		inv[i] /= det
+	}
+
+	return inv, nil
+}
+
+// PerspectiveFromPoints returns the Perspective that maps each of the four points from onto the same point of to,
+// no three of them may lie on a line
+// to rectify a document, pass the corners of the output image as from and the corners of the document in the input as to
+func PerspectiveFromPoints(from, to [4]Point) (Perspective, error) {
+	if degenerate(from) || degenerate(to) {
+		return Perspective{}, errors.New("points are degenerate, three of them lie on a line")
+	}
+
+	// with i = 1, each pair of points gives two linear equations in the remaining eight unknowns
+	// a*x + b*y + c - g*x*u - h*y*u = u
+	// d*x + e*y + f - g*x*v - h*y*v = v
+	var a [8][9]float64
+	for k := range 4 {
+		x, y := from[k].X, from[k].Y
+		u, v := to[k].X, to[k].Y
+
+		a[2*k] = [9]float64{x, y, 1, 0, 0, 0, -x * u, -y * u, u}
+		a[2*k+1] = [9]float64{0, 0, 0, x, y, 1, -x * v, -y * v, v}
+	}
+
+	// gaussian elimination with partial pivoting
+	for col := range 8 {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return Perspective{}, errors.New("points are degenerate, three of them lie on a line")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for row := range 8 {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for j := col; j < 9; j++ {
+				a[row][j] -= f * a[col][j]
+			}
+		}
+	}
+
+	var m Perspective
+	for i := range 8 {
+		m[i] = a[i][8] / a[i][i]
+	}
+	m[8] = 1
+
+	return m, nil
+}
+
+// reports whether any three of the points lie on a line
+func degenerate(p [4]Point) bool {
+	for skip := range 4 {
+		var q []Point
+		for i := range 4 {
+			if i != skip {
+				q = append(q, p[i])
+			}
+		}
+
+		// twice the area of the triangle, compared to the squared length of its longest side
+		area := math.Abs((q[1].X-q[0].X)*(q[2].Y-q[0].Y) - (q[2].X-q[0].X)*(q[1].Y-q[0].Y))
+		side := max(math.Hypot(q[1].X-q[0].X, q[1].Y-q[0].Y), math.Hypot(q[2].X-q[0].X, q[2].Y-q[0].Y), math.Hypot(q[2].X-q[1].X, q[2].Y-q[1].Y))
+		if area <= 1e-9*side*side {
+			return true
+		}
+	}
+	return false
+}
+
+// Point is a point with continuous coordinates, integer values are the centers of pixels
+type Point struct {
+	X, Y float64
+}
+
+// Corners returns the centers of the corner pixels of r, starting at the top-left corner and going clockwise
+func Corners(r image.Rectangle) [4]Point {
+	return [4]Point{
+		{float64(r.Min.X), float64(r.Min.Y)},
+		{float64(r.Max.X - 1), float64(r.Min.Y)},
+		{float64(r.Max.X - 1), float64(r.Max.Y - 1)},
+		{float64(r.Min.X), float64(r.Max.Y - 1)},
+	}
+}
@@ -0,0 +1,109 @@
+package interpolator
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestAffine(t *testing.T) {
+	m := Translate(3, -2).Mul(RotationAffine(30)).Mul(Shear(0.5, 0))
+
+	inv, err := m.Invert()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []Point{{0, 0}, {10, 5}, {-3, 7.5}} {
+		x, y := inv.Mul(m).Map(p.X, p.Y)
+		if math.Abs(x-p.X) > 1e-9 || math.Abs(y-p.Y) > 1e-9 {
+			t.Errorf("expected %v to map onto itself but instead got (%f, %f)", p, x, y)
+		}
+	}
+
+	// clockwise on screen, where the y-axis points down
+	if x, y := RotationAffine(90).Map(1, 0); x != 0 || y != 1 {
+		t.Errorf("expected (1, 0) to rotate onto (0, 1) but instead got (%f, %f)", x, y)
+	}
+
+	if _, err := (Affine{1, 2, 0, 2, 4, 0}).Invert(); err == nil {
+		t.Error("expected a singular affine transform not to be invertible")
+	}
+}
+
+func TestPerspectiveFromPoints(t *testing.T) {
+	from := Corners(image.Rect(0, 0, 100, 50))
+	to := [4]Point{{10, 5}, {80, 12}, {95, 60}, {3, 40}}
+
+	m, err := PerspectiveFromPoints(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k := range 4 {
+		x, y := m.Map(from[k].X, from[k].Y)
+		if math.Abs(x-to[k].X) > 1e-6 || math.Abs(y-to[k].Y) > 1e-6 {
+			t.Errorf("expected %v to map onto %v but instead got (%f, %f)", from[k], to[k], x, y)
+		}
+	}
+
+	inv, err := m.Invert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := range 4 {
+		x, y := inv.Map(to[k].X, to[k].Y)
+		if math.Abs(x-from[k].X) > 1e-6 || math.Abs(y-from[k].Y) > 1e-6 {
+			t.Errorf("expected %v to map back onto %v but instead got (%f, %f)", to[k], from[k], x, y)
+		}
+	}
+
+	// three points on a line
+	if _, err := PerspectiveFromPoints(from, [4]Point{{0, 0}, {1, 1}, {2, 2}, {0, 5}}); err == nil {
+		t.Error("expected degenerate points to fail")
+	}
+}
+
+func TestWarp(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	blue := color.NRGBA{0, 0, 255, 255}
+
+	// red square in the middle of a white image
+	src := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	for y := range 20 {
+		for x := range 20 {
+			if x >= 5 && x < 15 && y >= 5 && y < 15 {
+				src.Set(x, y, red)
+			} else {
+				src.Set(x, y, color.White)
+			}
+		}
+	}
+
+	// rectify the red square into the whole output
+	m, err := PerspectiveFromPoints(Corners(image.Rect(0, 0, 30, 30)), Corners(image.Rect(5, 5, 15, 15)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{"nearestneighbor", "bilinear", "bicubic"} {
+		actual := NewWarp(src, 30, 30, m, nil, method, Options{}).Interpolate(true)
+		for y := range 30 {
+			for x := range 30 {
+				if c := actual.NRGBAAt(x, y); c != red {
+					t.Fatalf("%s: expected the whole output to be red but instead got %v at [%d, %d]", method, c, x, y)
+				}
+			}
+		}
+
+		// shifting left by 2 pixels uncovers the background on the right
+		actual = NewWarp(src, 20, 20, Translate(2, 0), blue, method, Options{}).Interpolate(false)
+		if c := actual.NRGBAAt(19, 10); c != blue {
+			t.Errorf("%s: expected the background on the right edge but instead got %v", method, c)
+		}
+		if c := actual.NRGBAAt(3, 10); c != red {
+			t.Errorf("%s: expected the square to move left but instead got %v", method, c)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package interpolator
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"log"
+	"math"
+
+	"gthub.com/obzva/image-resize/parallel"
+)
+
+// sampler calculates the color values at any point of the input space of an interpolator
+// integer coordinates are the centers of input pixels
+type sampler interface {
+	sample(tX, tY float64) [4]float64
+}
+
+// Warp maps every output pixel onto the input through a Transform and samples the input there
+// with an interpolation method, for skew correction, document rectification or any other geometric effect
+// output pixels mapping outside of the input keep the background, unless Options.Edge is EdgeReflect or EdgeWrap
+// which repeat the input over the whole output
+type Warp struct {
+	interpolation
+	input, output raster
+	sampler       sampler
+	transform     Transform
+}
+
+// initialize Warp with a w x h output
+// available methods are the same as New
+// background fills the parts of the output the input doesn't cover, transparent when nil
+// *image.YCbCr sources are converted into *image.NRGBA, other kinds are kept, see NewImage
+func NewWarp(src image.Image, w, h int, t Transform, background color.Color, method string, opts Options) Interpolator {
+	return newWarp(src, w, h, t, background, method, method, opts)
+}
+
+// same as NewWarp, but samples the input with sampling instead of method
+func newWarp(src image.Image, w, h int, t Transform, background color.Color, method, sampling string, opts Options) *Warp {
+	if _, ok := src.(*image.YCbCr); ok {
+		src = ToNRGBA(src)
+	}
+
+	// nearest neighbor copies pixels as they are, there is nothing to blend in linear light
+	linear := opts.Linear && sampling != "nearestneighbor"
+
+	wa := &Warp{transform: t}
+	wa.input = newRaster(src, linear)
+	wa.output = newRasterLike(wa.input, w, h, linear)
+	wa.interpolation = newInterpolation(method, wa.input.Bounds(), wa.output.Bounds(), opts, wa.run)
+
+	if background != nil {
+		if dst, ok := wa.output.image().(draw.Image); ok {
+			draw.Draw(dst, dst.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+		}
+	}
+
+	// only interpolators reading the input at any point can sample warps
+	sampler, ok := newInterpolator(wa.input, wa.output, sampling, opts).(sampler)
+	if !ok {
+		log.Fatal("interpolation method can't sample warps")
+	}
+	wa.sampler = sampler
+
+	return wa
+}
+
+func (wa *Warp) run(ctx context.Context, concurrency bool) (image.Image, error) {
+	iW := float64(wa.input.Bounds().Dx())
+	iH := float64(wa.input.Bounds().Dy())
+
+	oW := wa.output.Bounds().Dx()
+	oH := wa.output.Bounds().Dy()
+
+	// reflected and wrapped inputs cover the whole plane
+	tiled := wa.opts.Edge == EdgeReflect || wa.opts.Edge == EdgeWrap
+
+	err := parallel.RunPixels(ctx, concurrency, wa.opts.parallel(), oW, oH, func(x, y int) {
+		tX, tY := wa.transform.Map(float64(x), float64(y))
+
+		// leave the background where the input doesn't cover the output, NaN fails every comparison
+		if !(tX >= -0.5 && tX <= iW-0.5 && tY >= -0.5 && tY <= iH-0.5) && !(tiled && !math.IsNaN(tX) && !math.IsNaN(tY)) {
+			return
+		}
+
+		wa.output.set(x, y, wa.sampler.sample(tX, tY))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return wa.output.image(), nil
+}
+
+// Rotation describes a rotation around the center of an image
+type Rotation struct {
+	Degrees    float64     // clockwise angle
+	Expand     bool        // grow the canvas so that the whole rotated image fits, otherwise the size of the source is kept
+	Background color.Color // fills the parts of the canvas the source doesn't cover, defaults to transparent
+}
+
+// Size returns the size of the output of NewRotate for a source of size src
+func (r Rotation) Size(src image.Point) image.Point {
+	if !r.Expand {
+		return src
+	}
+
+	rotation := RotationAffine(r.Degrees)
+	cos, sin := math.Abs(rotation[0]), math.Abs(rotation[3])
+
+	// bounding box of the rotated image, ignoring rounding errors
+	return image.Pt(
+		int(math.Ceil(float64(src.X)*cos+float64(src.Y)*sin-1e-9)),
+		int(math.Ceil(float64(src.X)*sin+float64(src.Y)*cos-1e-9)),
+	)
+}
+
+// initialize a Warp rotating src around its center
+// multiples of 90 degrees are copied pixel by pixel through Flip when the canvas fits the rotated image exactly,
+// whatever the method, so that they are lossless
+func NewRotate(src image.Image, r Rotation, method string, opts Options) Interpolator {
+	iW, iH := src.Bounds().Dx(), src.Bounds().Dy()
+
+	rotation := RotationAffine(r.Degrees)
+	cos, sin := math.Abs(rotation[0]), math.Abs(rotation[3])
+
+	size := r.Size(src.Bounds().Size())
+	oW, oH := size.X, size.Y
+
+	if math.Mod(r.Degrees, 90) == 0 && ((cos == 1 && oW == iW && oH == iH) || (sin == 1 && oW == iH && oH == iW)) {
+		return newQuarterTurn(src, r.Degrees, method, opts)
+	}
+
+	// move the center of the output onto the origin, rotate back counterclockwise, and move the origin onto the center of the input
+	t := Translate(float64(iW-1)/2, float64(iH-1)/2).
+		Mul(RotationAffine(-r.Degrees)).
+		Mul(Translate(-float64(oW-1)/2, -float64(oH-1)/2))
+
+	return newWarp(src, oW, oH, t, r.Background, method, method, opts)
+}
+
+// quarterTurn rotates an image by a multiple of 90 degrees, mirroring its pixels with Flip
+type quarterTurn struct {
+	interpolation
+	input image.Image
+	ops   []string // Flip operations applied in order
+}
+
+// initialize a quarterTurn rotating src clockwise by degrees, a multiple of 90
+// src is read as newRaster would, so that images with 16 bits per channel keep them
+func newQuarterTurn(src image.Image, degrees float64, method string, opts Options) *quarterTurn {
+	q := &quarterTurn{input: newRaster(src, false).image()}
+
+	switch int(math.Mod(math.Mod(degrees, 360)+360, 360)) {
+	case 0:
+		// two mirrors cancel out, and leave a copy
+		q.ops = []string{FlipHorizontal, FlipHorizontal}
+	case 90:
+		q.ops = []string{Transpose, FlipHorizontal}
+	case 180:
+		q.ops = []string{FlipHorizontal, FlipVertical}
+	case 270:
+		q.ops = []string{Transpose, FlipVertical}
+	}
+
+	b := q.input.Bounds()
+	output := b
+	if len(q.ops) > 0 && q.ops[0] == Transpose {
+		output = image.Rect(0, 0, b.Dy(), b.Dx())
+	}
+	q.interpolation = newInterpolation(method, b, output, opts, q.run)
+
+	return q
+}
+
+func (q *quarterTurn) run(ctx context.Context, concurrency bool) (image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	output := q.input
+	for _, op := range q.ops {
+		output = Flip(output, op)
+	}
+
+	return output, nil
+}
@@ -0,0 +1,124 @@
+package interpolator
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRotateQuarterTurns(t *testing.T) {
+	// 3x2 image with a different gray value in every pixel
+	// 1 2 3
+	// 4 5 6
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i := range 6 {
+		src.Set(i%3, i/3, color.NRGBA{uint8(i + 1), 0, 0, 255})
+	}
+
+	tests := []struct {
+		degrees  float64
+		w, h     int
+		expected []uint8
+	}{
+		{90, 2, 3, []uint8{4, 1, 5, 2, 6, 3}},
+		{180, 3, 2, []uint8{6, 5, 4, 3, 2, 1}},
+		{270, 2, 3, []uint8{3, 6, 2, 5, 1, 4}},
+		{-90, 2, 3, []uint8{3, 6, 2, 5, 1, 4}},
+		{360, 3, 2, []uint8{1, 2, 3, 4, 5, 6}},
+	}
+
+	for _, tt := range tests {
+		for _, method := range []string{"nearestneighbor", "bilinear", "bicubic"} {
+			actual := NewRotate(src, Rotation{Degrees: tt.degrees, Expand: true}, method, Options{Linear: true}).Interpolate(true)
+
+			if actual.Bounds() != image.Rect(0, 0, tt.w, tt.h) {
+				t.Fatalf("%s %v: expected %dx%d output but instead got %v", method, tt.degrees, tt.w, tt.h, actual.Bounds())
+			}
+
+			for i, e := range tt.expected {
+				if c := actual.NRGBAAt(i%tt.w, i/tt.w); c.R != e || c.A != 255 {
+					t.Errorf("%s %v: expected R at [%d, %d] to be %d but instead got %v", method, tt.degrees, i%tt.w, i/tt.w, e, c)
+				}
+			}
+		}
+	}
+}
+
+func TestRotateQuarterTurns16(t *testing.T) {
+	// 3x2 translucent image with 16 bits per channel, 8 bits would lose its values
+	// and premultiplying the colors of its fully transparent first pixel
+	src := image.NewNRGBA64(image.Rect(0, 0, 3, 2))
+	for i := range 6 {
+		v := uint16(i*10007 + 1)
+		src.SetNRGBA64(i%3, i/3, color.NRGBA64{v, v ^ 0x5555, 65535 - v, uint16(i * 3)})
+	}
+
+	tests := []struct {
+		degrees  float64
+		w, h     int
+		expected []int // indices of the source pixels in the output
+	}{
+		{90, 2, 3, []int{3, 0, 4, 1, 5, 2}},
+		{180, 3, 2, []int{5, 4, 3, 2, 1, 0}},
+		{270, 2, 3, []int{2, 5, 1, 4, 0, 3}},
+		{0, 3, 2, []int{0, 1, 2, 3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		for _, method := range []string{"nearestneighbor", "bicubic"} {
+			output, err := NewRotate(src, Rotation{Degrees: tt.degrees, Expand: true}, method, Options{Linear: true}).InterpolateImage(context.Background(), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual, ok := output.(*image.NRGBA64)
+			if !ok || actual.Bounds() != image.Rect(0, 0, tt.w, tt.h) {
+				t.Fatalf("%s %v: expected %dx%d *image.NRGBA64 but instead got %T %v", method, tt.degrees, tt.w, tt.h, output, output.Bounds())
+			}
+
+			for i, e := range tt.expected {
+				x, y := i%tt.w, i/tt.w
+				if c, expected := actual.NRGBA64At(x, y), src.NRGBA64At(e%3, e/3); c != expected {
+					t.Errorf("%s %v: expected %v at [%d, %d] but instead got %v", method, tt.degrees, expected, x, y, c)
+				}
+			}
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	white := color.NRGBA{255, 255, 255, 255}
+	blue := color.NRGBA{0, 0, 255, 255}
+
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for i := 0; i < len(src.Pix); i += 4 {
+		src.Pix[i], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3] = 255, 255, 255, 255
+	}
+
+	for _, method := range []string{"nearestneighbor", "bilinear", "bicubic"} {
+		// expand fits the bounding box of the rotated image
+		actual := NewRotate(src, Rotation{Degrees: 30, Expand: true, Background: blue}, method, Options{}).Interpolate(false)
+		if actual.Bounds() != image.Rect(0, 0, 45, 38) {
+			t.Errorf("%s: expected 45x38 output but instead got %v", method, actual.Bounds())
+		}
+		if c := actual.NRGBAAt(22, 19); c != white {
+			t.Errorf("%s: expected the center to be white but instead got %v", method, c)
+		}
+		if c := actual.NRGBAAt(0, 0); c != blue {
+			t.Errorf("%s: expected the corner to be the background but instead got %v", method, c)
+		}
+
+		// keeping the size crops the rotated image, the background defaults to transparent
+		actual = NewRotate(src, Rotation{Degrees: 90}, method, Options{}).Interpolate(false)
+		if actual.Bounds() != src.Bounds() {
+			t.Errorf("%s: expected %v output but instead got %v", method, src.Bounds(), actual.Bounds())
+		}
+		if c := actual.NRGBAAt(20, 10); c != white {
+			t.Errorf("%s: expected the center to be white but instead got %v", method, c)
+		}
+		if c := actual.NRGBAAt(0, 10); c.A != 0 {
+			t.Errorf("%s: expected the left edge to be transparent but instead got %v", method, c)
+		}
+	}
+}
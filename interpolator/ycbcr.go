@@ -0,0 +1,89 @@
+package interpolator
+
+import (
+	"context"
+	"image"
+	"image/color"
+)
+
+// YCbCr interpolates each plane of a chroma subsampled *image.YCbCr on its own, so that
+// JPEG images don't have to be converted into RGB and their chroma subsampling is kept
+type YCbCr struct {
+	interpolation
+	input, output *image.YCbCr
+	planes        [3]Interpolator // Y, Cb and Cr
+}
+
+func newYCbCr(src *image.YCbCr, w, h int, method string, opts Options) *YCbCr {
+	output := image.NewYCbCr(image.Rect(0, 0, w, h), src.SubsampleRatio)
+
+	iCW, iCH := chromaSize(src.Rect, src.SubsampleRatio)
+	oCW, oCH := chromaSize(output.Rect, output.SubsampleRatio)
+
+	// Y, Cb and Cr are not sRGB-encoded, and progress and stats are reported for the whole image
+	planeOpts := opts
+	planeOpts.Linear = false
+	planeOpts.Observer = nil
+	planeOpts.Progress = nil
+
+	// every plane reads its own component of the edge color
+	edgeColor := color.Color(color.Black)
+	if opts.EdgeColor != nil {
+		edgeColor = opts.EdgeColor
+	}
+	ec := color.YCbCrModel.Convert(edgeColor).(color.YCbCr)
+	cbOpts, crOpts := planeOpts, planeOpts
+	cbOpts.EdgeColor = color.Gray{ec.Cb}
+	crOpts.EdgeColor = color.Gray{ec.Cr}
+
+	// only the rows of the Y plane are reported as progress, it is the largest one
+	yOpts := planeOpts
+	yOpts.Progress = opts.Progress
+	yOpts.EdgeColor = color.Gray{ec.Y}
+
+	yc := &YCbCr{input: src, output: output}
+	yc.interpolation = newInterpolation(method, src.Rect, output.Rect, opts, yc.run)
+	yc.planes[0] = newInterpolator(
+		&planeRaster{src.Y, src.YStride, src.Rect, false, src},
+		&planeRaster{output.Y, output.YStride, output.Rect, false, output},
+		method, yOpts)
+	yc.planes[1] = newInterpolator(
+		&planeRaster{src.Cb, src.CStride, image.Rect(0, 0, iCW, iCH), false, src},
+		&planeRaster{output.Cb, output.CStride, image.Rect(0, 0, oCW, oCH), false, output},
+		method, cbOpts)
+	yc.planes[2] = newInterpolator(
+		&planeRaster{src.Cr, src.CStride, image.Rect(0, 0, iCW, iCH), false, src},
+		&planeRaster{output.Cr, output.CStride, image.Rect(0, 0, oCW, oCH), false, output},
+		method, crOpts)
+
+	return yc
+}
+
+func (yc *YCbCr) run(ctx context.Context, concurrency bool) (image.Image, error) {
+	for _, p := range yc.planes {
+		if _, err := p.InterpolateImage(ctx, concurrency); err != nil {
+			return nil, err
+		}
+	}
+
+	return yc.output, nil
+}
+
+// returns the size of the chroma planes of an *image.YCbCr with bounds r starting at (0, 0)
+func chromaSize(r image.Rectangle, ratio image.YCbCrSubsampleRatio) (w, h int) {
+	w, h = r.Dx(), r.Dy()
+
+	switch ratio {
+	case image.YCbCrSubsampleRatio422:
+		return (w + 1) / 2, h
+	case image.YCbCrSubsampleRatio420:
+		return (w + 1) / 2, (h + 1) / 2
+	case image.YCbCrSubsampleRatio440:
+		return w, (h + 1) / 2
+	case image.YCbCrSubsampleRatio411:
+		return (w + 3) / 4, h
+	case image.YCbCrSubsampleRatio410:
+		return (w + 3) / 4, (h + 1) / 2
+	}
+	return w, h
+}
@@ -0,0 +1,343 @@
+// Package job reads resize jobs from JSON or YAML files: which inputs to read,
+// and which outputs to write from each of them through which operations.
+//
+// A job looks like
+//
+//	inputs:
+//	  - photos/*.jpg
+//	outputs:
+//	  - path: thumbs/{name}-{width}.{ext}
+//	    width: 300
+//	    height: 300
+//	    fit: fill
+//	    method: mitchell
+//	    quality: 85
+//	    operations:
+//	      - sharpen: {radius: 1, amount: 0.5}
+//	      - pad: {top: 10, bottom: 10, background: ffffff}
+//
+// Every input is written once per output. The size fields resize first, then the operations run in order.
+package job
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"gthub.com/obzva/image-resize/filter"
+	"gthub.com/obzva/image-resize/hexcolor"
+	"gthub.com/obzva/image-resize/interpolator"
+	"gthub.com/obzva/image-resize/pipeline"
+)
+
+// Job describes the inputs and outputs of a job file
+type Job struct {
+	Inputs      []string `json:"inputs" yaml:"inputs"`           // paths of the input images, glob patterns are expanded
+	Outputs     []Output `json:"outputs" yaml:"outputs"`         // targets written for every input
+	Concurrency *bool    `json:"concurrency" yaml:"concurrency"` // concurrency mode, defaults to true
+	Workers     int      `json:"workers" yaml:"workers"`         // number of goroutines in concurrency mode, defaults to the number of CPUs
+}
+
+// Output describes one target written for every input
+type Output struct {
+	// path of the output file, {name} is replaced by the file name of the input without its extension,
+	// {ext} by the extension of the format, and {width} and {height} by the size of the output
+	Path string `json:"path" yaml:"path"`
+	// "jpeg" | "png", defaults to the extension of Path and then to the format of the input
+	Format  string `json:"format" yaml:"format"`
+	Quality int    `json:"quality" yaml:"quality"` // jpeg quality from 1 to 100, defaults to 75
+
+	// resizes the input before the operations when either is set, 0 keeps the aspect ratio
+	Width  int `json:"width" yaml:"width"`
+	Height int `json:"height" yaml:"height"`
+	// "fit" (inside of the size), "fill" (cover the size, cropping the rest) or "force" (stretch), defaults to fit
+	Fit     string `json:"fit" yaml:"fit"`
+	Gravity string `json:"gravity" yaml:"gravity"` // part of the input kept by fill: no, so, ea, we, noea, nowe, soea, sowe or ce (default)
+	Enlarge bool   `json:"enlarge" yaml:"enlarge"` // allow the output to be larger than the input
+
+	// interpolation method of the resize and of the operations that don't set one, defaults to nearestneighbor
+	Method string `json:"method" yaml:"method"`
+	Linear bool   `json:"linear" yaml:"linear"` // interpolate in linear light
+
+	Operations []Operation `json:"operations" yaml:"operations"`
+}
+
+// Operation is one step after the resize, exactly one of its fields has to be set
+type Operation struct {
+	Resize  *Resize  `json:"resize,omitempty" yaml:"resize,omitempty"`
+	Crop    *Crop    `json:"crop,omitempty" yaml:"crop,omitempty"`
+	Rotate  *Rotate  `json:"rotate,omitempty" yaml:"rotate,omitempty"`
+	Flip    string   `json:"flip,omitempty" yaml:"flip,omitempty"` // horizontal, vertical, transpose or transverse
+	Sharpen *Sharpen `json:"sharpen,omitempty" yaml:"sharpen,omitempty"`
+	Blur    *Blur    `json:"blur,omitempty" yaml:"blur,omitempty"`
+	Pad     *Pad     `json:"pad,omitempty" yaml:"pad,omitempty"`
+	Convert string   `json:"convert,omitempty" yaml:"convert,omitempty"` // nrgba, nrgba64, gray or gray16
+}
+
+// Resize is the same as the size fields of Output
+type Resize struct {
+	Width   int    `json:"width" yaml:"width"`
+	Height  int    `json:"height" yaml:"height"`
+	Fit     string `json:"fit" yaml:"fit"`
+	Gravity string `json:"gravity" yaml:"gravity"`
+	Enlarge bool   `json:"enlarge" yaml:"enlarge"`
+	Method  string `json:"method" yaml:"method"` // defaults to the method of the output
+}
+
+// Crop keeps width x height pixels from (x, y)
+type Crop struct {
+	X      int `json:"x" yaml:"x"`
+	Y      int `json:"y" yaml:"y"`
+	Width  int `json:"width" yaml:"width"`
+	Height int `json:"height" yaml:"height"`
+}
+
+// Rotate rotates clockwise around the center
+type Rotate struct {
+	Degrees    float64 `json:"degrees" yaml:"degrees"`
+	Expand     bool    `json:"expand" yaml:"expand"`         // grow the canvas to fit the whole rotated image
+	Background string  `json:"background" yaml:"background"` // hex RRGGBB or RRGGBBAA, defaults to transparent
+	Method     string  `json:"method" yaml:"method"`         // defaults to the method of the output
+}
+
+// Sharpen applies an unsharp mask
+type Sharpen struct {
+	Radius    float64 `json:"radius" yaml:"radius"` // defaults to 1
+	Amount    float64 `json:"amount" yaml:"amount"`
+	Threshold float64 `json:"threshold" yaml:"threshold"`
+}
+
+// returns the parameters of the unsharp mask, with the default radius when it is 0
+func (s *Sharpen) sharpening() filter.Sharpening {
+	sh := filter.Sharpening{Radius: s.Radius, Amount: s.Amount, Threshold: s.Threshold}
+	if sh.Radius == 0 {
+		sh.Radius = 1
+	}
+	return sh
+}
+
+// Blur applies a gaussian blur
+type Blur struct {
+	Sigma float64 `json:"sigma" yaml:"sigma"`
+}
+
+// Pad adds borders
+type Pad struct {
+	Top        int    `json:"top" yaml:"top"`
+	Right      int    `json:"right" yaml:"right"`
+	Bottom     int    `json:"bottom" yaml:"bottom"`
+	Left       int    `json:"left" yaml:"left"`
+	Background string `json:"background" yaml:"background"` // hex RRGGBB or RRGGBBAA, defaults to transparent
+}
+
+// Load reads and validates the job file at path, YAML unless its extension is .json
+// unknown fields are errors, so that typos don't go unnoticed
+func Load(path string) (*Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var j Job
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.DisallowUnknownFields()
+		err = d.Decode(&j)
+	} else {
+		d := yaml.NewDecoder(bytes.NewReader(data))
+		d.KnownFields(true)
+		err = d.Decode(&j)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := j.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid job %s:\n%w", path, err)
+	}
+
+	return &j, nil
+}
+
+// collects the errors found by Validate, each of them prefixed by where it was found
+type problems []error
+
+func (p *problems) add(field, format string, args ...any) {
+	*p = append(*p, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+}
+
+// Validate checks the fields of the job without reading any image, and reports every problem it finds
+func (j *Job) Validate() error {
+	var p problems
+
+	if len(j.Inputs) == 0 {
+		p.add("inputs", "at least one input is required")
+	}
+	for i, in := range j.Inputs {
+		if _, err := filepath.Match(in, ""); in == "" || err != nil {
+			p.add(fmt.Sprintf("inputs[%d]", i), "invalid path or pattern %q", in)
+		}
+	}
+
+	if len(j.Outputs) == 0 {
+		p.add("outputs", "at least one output is required")
+	}
+	if j.Workers < 0 {
+		p.add("workers", "can't be negative, got %d", j.Workers)
+	}
+
+	for i, o := range j.Outputs {
+		o.validate(&p, fmt.Sprintf("outputs[%d]", i))
+	}
+
+	return errors.Join(p...)
+}
+
+func (o *Output) validate(p *problems, field string) {
+	if o.Path == "" {
+		p.add(field+".path", "is required")
+	}
+	if o.Format != "" && format(o.Format) == "" {
+		p.add(field+".format", "unknown format %q, expected jpeg or png", o.Format)
+	}
+	if o.Quality < 0 || o.Quality > 100 {
+		p.add(field+".quality", "has to be from 1 to 100, got %d", o.Quality)
+	}
+
+	validateSize(p, field, o.Width, o.Height, o.Fit, o.Gravity, o.Method, false)
+
+	for i, op := range o.Operations {
+		op.validate(p, fmt.Sprintf("%s.operations[%d]", field, i))
+	}
+}
+
+// checks the fields shared by Output and Resize, the size is required for Resize operations
+func validateSize(p *problems, field string, w, h int, fit, gravity, method string, required bool) {
+	if w < 0 || h < 0 {
+		p.add(field, "width and height can't be negative, got %dx%d", w, h)
+	}
+	if required && w == 0 && h == 0 {
+		p.add(field, "width, height or both are required")
+	}
+
+	switch fit {
+	case "", "fit", "fill", "force":
+	default:
+		p.add(field+".fit", "unknown fit %q, expected fit, fill or force", fit)
+	}
+
+	switch gravity {
+	case "", "no", "so", "ea", "we", "noea", "nowe", "soea", "sowe", "ce":
+	default:
+		p.add(field+".gravity", "unknown gravity %q, expected no, so, ea, we, noea, nowe, soea, sowe or ce", gravity)
+	}
+
+	if method != "" && !knownMethod(method) {
+		p.add(field+".method", "unknown interpolation method %q", method)
+	}
+}
+
+func (op *Operation) validate(p *problems, field string) {
+	var set []string
+	for name, ok := range map[string]bool{
+		"resize":  op.Resize != nil,
+		"crop":    op.Crop != nil,
+		"rotate":  op.Rotate != nil,
+		"flip":    op.Flip != "",
+		"sharpen": op.Sharpen != nil,
+		"blur":    op.Blur != nil,
+		"pad":     op.Pad != nil,
+		"convert": op.Convert != "",
+	} {
+		if ok {
+			set = append(set, name)
+		}
+	}
+	if len(set) != 1 {
+		p.add(field, "exactly one of resize, crop, rotate, flip, sharpen, blur, pad and convert has to be set, got %d", len(set))
+		return
+	}
+
+	switch {
+	case op.Resize != nil:
+		r := op.Resize
+		validateSize(p, field+".resize", r.Width, r.Height, r.Fit, r.Gravity, r.Method, true)
+	case op.Crop != nil:
+		if op.Crop.X < 0 || op.Crop.Y < 0 || op.Crop.Width <= 0 || op.Crop.Height <= 0 {
+			p.add(field+".crop", "expected x and y of at least 0 and a positive width and height")
+		}
+	case op.Rotate != nil:
+		if op.Rotate.Method != "" && !knownMethod(op.Rotate.Method) {
+			p.add(field+".rotate.method", "unknown interpolation method %q", op.Rotate.Method)
+		}
+		if _, err := parseColor(op.Rotate.Background); err != nil {
+			p.add(field+".rotate.background", "%v", err)
+		}
+	case op.Flip != "":
+		switch op.Flip {
+		case "horizontal", "vertical", "transpose", "transverse":
+		default:
+			p.add(field+".flip", "unknown flip %q, expected horizontal, vertical, transpose or transverse", op.Flip)
+		}
+	case op.Sharpen != nil:
+		if _, err := (pipeline.Sharpen{Sharpening: op.Sharpen.sharpening()}).Validate(image.Point{}); err != nil {
+			p.add(field+".sharpen", "%v", err)
+		}
+	case op.Blur != nil:
+		if op.Blur.Sigma <= 0 {
+			p.add(field+".blur.sigma", "has to be positive, got %v", op.Blur.Sigma)
+		}
+	case op.Pad != nil:
+		if op.Pad.Top < 0 || op.Pad.Right < 0 || op.Pad.Bottom < 0 || op.Pad.Left < 0 {
+			p.add(field+".pad", "borders can't be negative")
+		}
+		if _, err := parseColor(op.Pad.Background); err != nil {
+			p.add(field+".pad.background", "%v", err)
+		}
+	case op.Convert != "":
+		switch op.Convert {
+		case "nrgba", "nrgba64", "gray", "gray16":
+		default:
+			p.add(field+".convert", "unknown color model %q, expected nrgba, nrgba64, gray or gray16", op.Convert)
+		}
+	}
+}
+
+// returns "jpeg" or "png" for the names and extensions of the supported formats, "" for any other
+func format(s string) string {
+	switch strings.ToLower(strings.TrimPrefix(s, ".")) {
+	case "jpg", "jpeg":
+		return "jpeg"
+	case "png":
+		return "png"
+	}
+	return ""
+}
+
+// reports whether method is an interpolation method, whatever the size it resizes into
+func knownMethod(method string) bool {
+	err := interpolator.Validate(method, image.Pt(1, 1), image.Pt(1, 1))
+	return err == nil || errors.Is(err, interpolator.ErrScale)
+}
+
+// parses RRGGBB or RRGGBBAA, with or without a leading #, nil for ""
+func parseColor(s string) (color.Color, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	c, err := hexcolor.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
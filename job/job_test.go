@@ -0,0 +1,258 @@
+package job
+
+import (
+	"context"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gthub.com/obzva/image-resize/pipeline"
+)
+
+// writes content into a file named name in a temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	yamlPath := writeFile(t, "job.yaml", `
+inputs: [../assets/images/test-image.jpg]
+workers: 2
+outputs:
+  - path: out/{name}.{ext}
+    width: 100
+    fit: fill
+    operations:
+      - sharpen: {amount: 0.5}
+`)
+	jsonPath := writeFile(t, "job.json", `{
+	"inputs": ["../assets/images/test-image.jpg"],
+	"workers": 2,
+	"outputs": [{"path": "out/{name}.{ext}", "width": 100, "fit": "fill", "operations": [{"sharpen": {"amount": 0.5}}]}]
+}`)
+
+	for _, path := range []string{yamlPath, jsonPath} {
+		j, err := Load(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if len(j.Inputs) != 1 || j.Workers != 2 || len(j.Outputs) != 1 {
+			t.Fatalf("%s: unexpected job %+v", path, j)
+		}
+		o := j.Outputs[0]
+		if o.Width != 100 || o.Fit != "fill" || len(o.Operations) != 1 || o.Operations[0].Sharpen == nil || o.Operations[0].Sharpen.Amount != 0.5 {
+			t.Errorf("%s: unexpected output %+v", path, o)
+		}
+	}
+
+	// typos are errors instead of being ignored
+	for name, content := range map[string]string{
+		"typo.yaml": "inputs: [a.jpg]\noutputs:\n  - path: a.png\n    widht: 10\n",
+		"typo.json": `{"inputs": ["a.jpg"], "outputs": [{"path": "a.png", "widht": 10}]}`,
+	} {
+		if _, err := Load(writeFile(t, name, content)); err == nil || !strings.Contains(err.Error(), "widht") {
+			t.Errorf("%s: expected an error about widht but instead got %v", name, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	j := &Job{
+		Outputs: []Output{{
+			Fit:     "cover",
+			Quality: 101,
+			Method:  "lanczos",
+			Operations: []Operation{
+				{},
+				{Sharpen: &Sharpen{Amount: 1}, Blur: &Blur{Sigma: 1}},
+				{Pad: &Pad{Background: "white"}},
+				{Resize: &Resize{Fit: "fill"}},
+				{Convert: "cmyk"},
+			},
+		}},
+	}
+
+	err := j.Validate()
+	if err == nil {
+		t.Fatal("expected errors but instead got nil")
+	}
+
+	// every problem is reported, with where it was found
+	for _, want := range []string{
+		"inputs: ",
+		"outputs[0].path: ",
+		"outputs[0].quality: ",
+		"outputs[0].fit: ",
+		"outputs[0].method: ",
+		"outputs[0].operations[0]: ",
+		"outputs[0].operations[1]: ",
+		"outputs[0].operations[2].pad.background: ",
+		"outputs[0].operations[3].resize: ",
+		"outputs[0].operations[4].convert: ",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected a problem starting with %q but instead got\n%v", want, err)
+		}
+	}
+}
+
+func TestValidateSharpen(t *testing.T) {
+	// the same sharpenings as pipeline.Sharpen are valid, with a radius of 1 when omitted
+	for _, c := range []struct {
+		sharpen Sharpen
+		ok      bool
+	}{
+		{Sharpen{Amount: 0.5}, true},
+		{Sharpen{Radius: 2}, true},
+		{Sharpen{Radius: -1, Amount: 1}, false},
+		{Sharpen{Amount: -1}, false},
+		{Sharpen{Amount: 1, Threshold: -1}, false},
+	} {
+		j := &Job{
+			Inputs:  []string{"a.jpg"},
+			Outputs: []Output{{Path: "a.png", Width: 10, Operations: []Operation{{Sharpen: &c.sharpen}}}},
+		}
+		if err := j.Validate(); (err == nil) != c.ok {
+			t.Errorf("%+v: expected ok to be %t but instead got %v", c.sharpen, c.ok, err)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	dir := t.TempDir()
+	j := &Job{
+		Inputs: []string{"../assets/images/test-*.jpg"},
+		Outputs: []Output{
+			{
+				Path:    filepath.Join(dir, "{name}-{width}x{height}.{ext}"),
+				Width:   200,
+				Height:  200,
+				Fit:     "fill",
+				Method:  "bilinear",
+				Quality: 90,
+				Operations: []Operation{
+					{Pad: &Pad{Top: 5, Bottom: 5, Background: "ffffff"}},
+					{Flip: "transpose"},
+				},
+			},
+			{Path: filepath.Join(dir, "gray.png"), Width: 100, Operations: []Operation{{Convert: "gray"}}},
+		},
+	}
+	if err := j.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := j.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("expected 2 tasks but instead got %d", len(tasks))
+	}
+
+	fill := tasks[0]
+	if fill.Output != filepath.Join(dir, "test-image-210x200.jpg") || fill.Format != "jpeg" || fill.Quality != 90 || fill.OutputSize != image.Pt(210, 200) {
+		t.Errorf("unexpected task %+v", fill)
+	}
+	// crop to a square, resize, pad and flip
+	if len(fill.Steps) != 4 || !strings.HasPrefix(fill.Steps[0], "crop (100,0)-(400,300)") {
+		t.Errorf("unexpected steps\n%s", fill)
+	}
+
+	gray := tasks[1]
+	if gray.Format != "png" || gray.OutputSize != image.Pt(100, 60) {
+		t.Errorf("unexpected task %+v", gray)
+	}
+
+	for _, task := range tasks {
+		timings, err := task.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(timings) != len(task.Steps) {
+			t.Errorf("expected %d timings but instead got %d", len(task.Steps), len(timings))
+		}
+
+		f, err := os.Open(task.Output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, format, err := image.DecodeConfig(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != task.Format || image.Pt(config.Width, config.Height) != task.OutputSize {
+			t.Errorf("expected a %v %s file but instead got %dx%d %s", task.OutputSize, task.Format, config.Width, config.Height, format)
+		}
+	}
+
+	// two inputs can't be written into the same file
+	j.Inputs = append(j.Inputs, "../assets/images/bicubic.jpg")
+	if _, err := j.Plan(); err == nil || !strings.Contains(err.Error(), "gray.png is already written") {
+		t.Errorf("expected an error about gray.png but instead got %v", err)
+	}
+
+	// sizes are checked against the inputs
+	j.Inputs = j.Inputs[:1]
+	j.Outputs[1].Operations = append(j.Outputs[1].Operations, Operation{Crop: &Crop{Width: 200, Height: 10}})
+	if _, err := j.Plan(); err == nil || !strings.Contains(err.Error(), "outputs[1]: operations[1].crop") {
+		t.Errorf("expected an error about the crop but instead got %v", err)
+	}
+}
+
+// empty is a step passing validation whose output is an empty image
+type empty struct{}
+
+func (empty) Name() string {
+	return "empty"
+}
+
+func (empty) Validate(size image.Point) (image.Point, error) {
+	return size, nil
+}
+
+func (empty) Apply(ctx context.Context, src image.Image, concurrency bool) (image.Image, error) {
+	return image.NewNRGBA(image.Rectangle{}), nil
+}
+
+func TestRunFailure(t *testing.T) {
+	dir := t.TempDir()
+	j := &Job{
+		Inputs:  []string{"../assets/images/test-image.jpg"},
+		Outputs: []Output{{Path: filepath.Join(dir, "{name}.png"), Width: 100}},
+	}
+	if err := j.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := j.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := tasks[0]
+
+	// canceled before the image is ready
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := task.Run(ctx); err == nil {
+		t.Error("expected an error for a canceled task but instead got nil")
+	}
+	if _, err := os.Stat(task.Output); !os.IsNotExist(err) {
+		t.Errorf("expected no %s after a canceled task but instead got %v", task.Output, err)
+	}
+
+	// png can't encode an empty image, the file created for it is removed
+	task.pipeline = pipeline.New(empty{})
+	if _, err := task.Run(context.Background()); err == nil {
+		t.Error("expected an error for an empty image but instead got nil")
+	}
+	if _, err := os.Stat(task.Output); !os.IsNotExist(err) {
+		t.Errorf("expected no %s after a failed encoding but instead got %v", task.Output, err)
+	}
+}
@@ -0,0 +1,292 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gthub.com/obzva/image-resize/filter"
+	"gthub.com/obzva/image-resize/imgproxy"
+	"gthub.com/obzva/image-resize/interpolator"
+	"gthub.com/obzva/image-resize/pipeline"
+)
+
+// Task writes one output of one input, resolved against the size of the input
+type Task struct {
+	Input       string
+	InputSize   image.Point
+	Output      string
+	OutputSize  image.Point
+	Format      string   // "jpeg" | "png"
+	Quality     int      // jpeg quality
+	Steps       []string // descriptions of the steps, in order
+	Concurrency bool
+
+	pipeline *pipeline.Pipeline
+}
+
+// String describes the task over several lines, one per step
+func (t Task) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s (%dx%d) -> %s (%dx%d %s", t.Input, t.InputSize.X, t.InputSize.Y, t.Output, t.OutputSize.X, t.OutputSize.Y, t.Format)
+	if t.Format == "jpeg" {
+		fmt.Fprintf(&b, ", quality %d", t.Quality)
+	}
+	b.WriteString(")")
+
+	if len(t.Steps) == 0 {
+		b.WriteString("\n  copy")
+	}
+	for _, s := range t.Steps {
+		b.WriteString("\n  " + s)
+	}
+
+	return b.String()
+}
+
+// Plan expands the inputs of j and resolves every output against every input, reading only the headers of the inputs
+// the tasks are ordered by input, then by output
+func (j *Job) Plan() ([]Task, error) {
+	var inputs []string
+	for i, in := range j.Inputs {
+		matches, err := filepath.Glob(in)
+		if err != nil {
+			return nil, fmt.Errorf("inputs[%d]: %w", i, err)
+		}
+
+		// plain paths are kept, so that missing files are reported as such
+		if len(matches) == 0 && strings.ContainsAny(in, `*?[\`) {
+			return nil, fmt.Errorf("inputs[%d]: no files match %q", i, in)
+		}
+		if len(matches) == 0 {
+			matches = []string{in}
+		}
+		inputs = append(inputs, matches...)
+	}
+
+	concurrency := j.Concurrency == nil || *j.Concurrency
+
+	var tasks []Task
+	written := map[string]string{}
+
+	for _, in := range inputs {
+		r, err := os.Open(in)
+		if err != nil {
+			return nil, err
+		}
+		config, iFormat, err := image.DecodeConfig(r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", in, err)
+		}
+
+		for i, o := range j.Outputs {
+			field := fmt.Sprintf("outputs[%d]", i)
+
+			t, err := j.resolve(o, in, image.Pt(config.Width, config.Height), iFormat)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", in, field, err)
+			}
+			t.Concurrency = concurrency
+
+			if prev, ok := written[t.Output]; ok {
+				return nil, fmt.Errorf("%s: %s: %s is already written by %s, use {name} in the path to tell the inputs apart", in, field, t.Output, prev)
+			}
+			written[t.Output] = fmt.Sprintf("%s of %s", field, in)
+
+			tasks = append(tasks, t)
+		}
+	}
+
+	return tasks, nil
+}
+
+// resolves the output o of the input at path in, of size and format iFormat
+func (j *Job) resolve(o Output, in string, size image.Point, iFormat string) (Task, error) {
+	t := Task{Input: in, InputSize: size, Quality: o.Quality}
+	if t.Quality == 0 {
+		t.Quality = jpeg.DefaultQuality
+	}
+
+	method := o.Method
+	if method == "" {
+		method = "nearestneighbor"
+	}
+	iOpts := interpolator.Options{Workers: j.Workers, Linear: o.Linear}
+	fOpts := filter.Options{Workers: j.Workers}
+
+	var steps []pipeline.Step
+	add := func(field string, step pipeline.Step, desc string) error {
+		out, err := step.Validate(size)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		steps = append(steps, step)
+		t.Steps = append(t.Steps, fmt.Sprintf("%s (%dx%d -> %dx%d)", desc, size.X, size.Y, out.X, out.Y))
+		size = out
+		return nil
+	}
+
+	// crops and resizes the current size by fit, as imgproxy does
+	resize := func(field string, r Resize) error {
+		if r.Method == "" {
+			r.Method = method
+		}
+		if r.Fit == "" {
+			r.Fit = "fit"
+		}
+
+		plan := imgproxy.Options{ResizingType: r.Fit, Width: r.Width, Height: r.Height, Enlarge: r.Enlarge, Gravity: r.Gravity}
+		crop, w, h := plan.Plan(size.X, size.Y)
+
+		if crop != image.Rect(0, 0, size.X, size.Y) {
+			if err := add(field, pipeline.Crop{Rect: crop}, fmt.Sprintf("crop %v", crop)); err != nil {
+				return err
+			}
+		}
+		if image.Pt(w, h) != size || r.Method == "seamcarve" {
+			return add(field, pipeline.Resize{Width: w, Height: h, Method: r.Method, Options: iOpts}, fmt.Sprintf("resize with %s, %s", r.Method, r.Fit))
+		}
+		return nil
+	}
+
+	if o.Width != 0 || o.Height != 0 {
+		r := Resize{Width: o.Width, Height: o.Height, Fit: o.Fit, Gravity: o.Gravity, Enlarge: o.Enlarge}
+		if err := resize("size", r); err != nil {
+			return t, err
+		}
+	}
+
+	for i, op := range o.Operations {
+		f := fmt.Sprintf("operations[%d]", i)
+
+		var err error
+		switch {
+		case op.Resize != nil:
+			err = resize(f+".resize", *op.Resize)
+		case op.Crop != nil:
+			c := op.Crop
+			rect := image.Rect(c.X, c.Y, c.X+c.Width, c.Y+c.Height)
+			err = add(f+".crop", pipeline.Crop{Rect: rect}, fmt.Sprintf("crop %v", rect))
+		case op.Rotate != nil:
+			m := op.Rotate.Method
+			if m == "" {
+				m = method
+			}
+			bg, _ := parseColor(op.Rotate.Background)
+			rotation := interpolator.Rotation{Degrees: op.Rotate.Degrees, Expand: op.Rotate.Expand, Background: bg}
+			err = add(f+".rotate", pipeline.Rotate{Rotation: rotation, Method: m, Options: iOpts}, fmt.Sprintf("rotate %v degrees with %s", op.Rotate.Degrees, m))
+		case op.Flip != "":
+			err = add(f+".flip", pipeline.Flip{Op: op.Flip}, "flip "+op.Flip)
+		case op.Sharpen != nil:
+			s := op.Sharpen.sharpening()
+			err = add(f+".sharpen", pipeline.Sharpen{Sharpening: s, Options: fOpts}, fmt.Sprintf("sharpen radius %v, amount %v, threshold %v", s.Radius, s.Amount, s.Threshold))
+		case op.Blur != nil:
+			err = add(f+".blur", pipeline.Blur{Sigma: op.Blur.Sigma, Options: fOpts}, fmt.Sprintf("blur sigma %v", op.Blur.Sigma))
+		case op.Pad != nil:
+			p := op.Pad
+			bg, _ := parseColor(p.Background)
+			pad := pipeline.Pad{Top: p.Top, Right: p.Right, Bottom: p.Bottom, Left: p.Left, Background: bg}
+			desc := fmt.Sprintf("pad top %d, right %d, bottom %d, left %d", p.Top, p.Right, p.Bottom, p.Left)
+			if p.Background != "" {
+				desc += " with " + p.Background
+			}
+			err = add(f+".pad", pad, desc)
+		case op.Convert != "":
+			err = add(f+".convert", pipeline.Convert{Model: op.Convert}, "convert to "+op.Convert)
+		}
+		if err != nil {
+			return t, err
+		}
+	}
+
+	t.OutputSize = size
+	t.pipeline = pipeline.New(steps...)
+
+	// the format comes from the field, the extension of the path, or the input
+	t.Format = format(o.Format)
+	if t.Format == "" {
+		t.Format = format(filepath.Ext(o.Path))
+	}
+	if t.Format == "" {
+		t.Format = format(iFormat)
+	}
+	if t.Format == "" {
+		return t, fmt.Errorf("can't write %s images, set the format to jpeg or png", iFormat)
+	}
+
+	ext := "png"
+	if t.Format == "jpeg" {
+		ext = "jpg"
+	}
+	name := strings.TrimSuffix(filepath.Base(in), filepath.Ext(in))
+	t.Output = strings.NewReplacer(
+		"{name}", name,
+		"{ext}", ext,
+		"{width}", strconv.Itoa(size.X),
+		"{height}", strconv.Itoa(size.Y),
+	).Replace(o.Path)
+
+	return t, nil
+}
+
+// Run reads the input of t, runs its steps and writes the output, creating its directory when needed
+// returns the timings of the steps
+func (t *Task) Run(ctx context.Context) ([]pipeline.Timing, error) {
+	if t.pipeline == nil {
+		return nil, errors.New("task was not planned, see Job.Plan")
+	}
+
+	r, err := os.Open(t.Input)
+	if err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(r)
+	r.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t.Input, err)
+	}
+
+	out, timings, err := t.pipeline.Run(ctx, src, t.Concurrency)
+	if err != nil {
+		return timings, fmt.Errorf("%s: %w", t.Input, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.Output), 0o755); err != nil {
+		return timings, err
+	}
+	// the file is only created once the image is ready, and removed when it can't be written,
+	// so that failures don't leave an empty or truncated one behind
+	f, err := os.Create(t.Output)
+	if err != nil {
+		return timings, err
+	}
+	if err := t.encode(f, out); err != nil {
+		f.Close()
+		os.Remove(t.Output)
+		return timings, fmt.Errorf("%s: %w", t.Output, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(t.Output)
+		return timings, err
+	}
+
+	return timings, nil
+}
+
+// encodes img into w in the output format of t
+func (t *Task) encode(w io.Writer, img image.Image) error {
+	if t.Format == "jpeg" {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: t.Quality})
+	}
+	return png.Encode(w, img)
+}
@@ -1,27 +1,69 @@
 package main
 
 import (
-	"flag"
+	"fmt"
 	"log"
-
-	"gthub.com/obzva/image-resize/imageprocessor"
+	"os"
+	"strings"
 )
 
-func main() {
-	// flags
-	pathPtr := flag.String("p", "", "input image path")
-	wPtr := flag.Int("w", 0, "desired width of output image, defaults to keep the ratio of the original image when omitted (at least one of two, width or height, is required)")
-	hPtr := flag.Int("h", 0, "desired height of output image, defaults to keep the ratio of the original image when omitted (at least one of two, width or height, is required)")
-	methodPtr := flag.String("m", "nearestneighbor", "desired interpolation method, defaults to nearestneighbor (options: nearestneighbor, bilinear, and bicubic)")
-	outputPtr := flag.String("o", "", "desired output filename, defaults to the method name when omitted")
-	concurrencyPtr := flag.Bool("c", true, "concurrency mode, defaults to true when omitted")
+// subcommands, in the order of the usage
+var commands = []struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}{
+	{"resize", "resize, flip, rotate, blur and sharpen one image (default)", runResize},
+	{"info", "print the size, format, color model and EXIF summary of images", runInfo},
+	{"compare", "print quality metrics of an image against a reference", runCompare},
+	{"batch", "run JSON or YAML job files", runBatch},
+	{"serve", "serve imgproxy-style resize URLs over HTTP", runServe},
+}
 
-	flag.Parse()
+func main() {
+	if len(os.Args) > 1 {
+		name := os.Args[1]
+		if name == "help" || name == "-help" || name == "--help" {
+			usage()
+			return
+		}
 
-	ip := imageprocessor.New(*pathPtr, *wPtr, *hPtr, *methodPtr, *concurrencyPtr, *outputPtr)
+		for _, c := range commands {
+			if c.name == name {
+				if err := c.run(os.Args[2:]); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+		}
+	}
 
-	err := ip.CreateImageFile()
-	if err != nil {
+	// without a subcommand, the arguments are the flags of resize, as before subcommands existed
+	if err := runResize(os.Args[1:]); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// prints the subcommands on stderr
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: image-resize <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nrun image-resize <command> -help for the flags of a command")
+}
+
+// width of the progress bar in characters
+const progressBarWidth = 40
+
+// draws a progress bar of completed rows on stderr
+func progressBar(done, total int) {
+	filled := progressBarWidth * done / total
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+	fmt.Fprintf(os.Stderr, "\r[%s] %3d%% (%d/%d rows)", bar, 100*done/total, done, total)
+	if done == total {
+		fmt.Fprintln(os.Stderr)
+	}
+}
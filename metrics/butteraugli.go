@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"image"
+	"math"
+)
+
+// ButteraugliLite returns a perceptual distance of img from ref, 0 for identical images, higher is more visible
+// it follows the outline of Butteraugli with fewer and simpler stages:
+//   - colors are compared in the XYB space of JPEG XL, modelling the response of the cones of the eye
+//   - each channel is split into high, medium and low frequencies, the blue channel counts little
+//     at the higher ones, which the eye barely resolves in blue
+//   - differences of the high and medium frequencies are masked by the texture around them,
+//     the smaller of the textures of both images, so that artifacts can't mask themselves
+//   - the distance is the one of the worst pixel
+//
+// its values are not the ones of Butteraugli, 1 is the distance between a flat gray of 128 and one of 130
+func ButteraugliLite(ref, img image.Image) (float64, error) {
+	if err := checkSize(ref, img); err != nil {
+		return 0, err
+	}
+
+	size := ref.Bounds().Size()
+
+	return distance(xyb(rgb(ref)), xyb(rgb(img)), size.X, size.Y) / grayStep, nil
+}
+
+// returns the distance of ButteraugliLite between the w x h XYB planes a and b, before it is scaled
+func distance(a, b [3][]float64, w, h int) float64 {
+	bandsA, bandsB := make([][3][]float64, 3), make([][3][]float64, 3)
+	for c := range 3 {
+		bandsA[c] = bands(a[c], w, h)
+		bandsB[c] = bands(b[c], w, h)
+	}
+
+	// texture of the luma of each image, from its high and medium frequencies
+	texture := func(y [3][]float64) []float64 {
+		t := make([]float64, len(y[0]))
+		for i := range t {
+			t[i] = math.Abs(y[0][i]) + math.Abs(y[1][i])
+		}
+		return convolve(t, w, h, maskKernel)
+	}
+	maskA, maskB := texture(bandsA[1]), texture(bandsB[1])
+
+	var worst float64
+	for i := range w * h {
+		m := min(maskA[i], maskB[i]) / maskScale
+		masking := 1 + m*m
+
+		var d float64
+		for c := range 3 {
+			for band := range 3 {
+				diff := bandWeights[c][band] * (bandsA[c][band][i] - bandsB[c][band][i])
+				if band < 2 {
+					d += diff * diff / masking
+				} else {
+					d += diff * diff
+				}
+			}
+		}
+		worst = max(worst, math.Sqrt(d))
+	}
+
+	return worst
+}
+
+// weights of the high, medium and low frequencies of the X, Y and B channels
+// X differences are far smaller than Y ones for changes of color as visible, and the eye resolves little blue detail
+var bandWeights = [3][3]float64{
+	{12, 12, 8},
+	{1, 1, 1},
+	{0.05, 0.2, 0.5},
+}
+
+// texture of the luma at which masking divides the squared differences of the high and medium frequencies by 2
+const maskScale = 0.02
+
+// gaussian windows splitting the frequencies, and averaging the texture of masking
+var (
+	highKernel = gaussian(1)
+	lowKernel  = gaussian(4)
+	maskKernel = gaussian(2.5)
+)
+
+// distance of the flat grays of 128 and 130, the unit of ButteraugliLite
+var grayStep = func() float64 {
+	gray := func(v float64) [3][]float64 {
+		return xyb([3][]float64{{v}, {v}, {v}})
+	}
+	return distance(gray(128), gray(130), 1, 1)
+}()
+
+// returns the high, medium and low frequencies of the w x h plane p, which add up to p
+func bands(p []float64, w, h int) [3][]float64 {
+	blurred, low := convolve(p, w, h, highKernel), convolve(p, w, h, lowKernel)
+
+	high, medium := make([]float64, len(p)), make([]float64, len(p))
+	for i := range p {
+		high[i] = p[i] - blurred[i]
+		medium[i] = blurred[i] - low[i]
+	}
+
+	return [3][]float64{high, medium, low}
+}
+
+// opsin absorbance of the long, medium and short cones, and its bias, from the XYB color space of JPEG XL
+var (
+	opsin = [3][3]float64{
+		{0.30, 0.622, 0.078},
+		{0.23, 0.692, 0.078},
+		{0.24342268924547819, 0.20476744424496821, 0.55180986650955360},
+	}
+	opsinBias = 0.0037930732552754493
+)
+
+// converts the sRGB planes p on a 0 to 255 scale into X, Y and B planes, in place
+func xyb(p [3][]float64) [3][]float64 {
+	cbrtBias := math.Cbrt(opsinBias)
+
+	for i := range p[0] {
+		var linear, lms [3]float64
+		for c := range linear {
+			linear[c] = srgbToLinear(p[c][i] / 255)
+		}
+		for c := range lms {
+			m := opsin[c][0]*linear[0] + opsin[c][1]*linear[1] + opsin[c][2]*linear[2] + opsinBias
+			lms[c] = math.Cbrt(m) - cbrtBias
+		}
+
+		p[0][i] = (lms[0] - lms[1]) / 2
+		p[1][i] = (lms[0] + lms[1]) / 2
+		p[2][i] = lms[2]
+	}
+
+	return p
+}
+
+// decodes an sRGB value from 0 to 1 into linear light
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
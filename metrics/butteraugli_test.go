@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"errors"
+	"image"
+	"math"
+	"testing"
+)
+
+func TestButteraugliLite(t *testing.T) {
+	ref := reference()
+
+	if d, err := ButteraugliLite(ref, ref); err != nil || d != 0 {
+		t.Errorf("expected a distance of 0 for identical images but instead got %v and %v", d, err)
+	}
+
+	// the unit is the step between two flat grays
+	a, b := image.NewGray(image.Rect(0, 0, 16, 16)), image.NewGray(image.Rect(0, 0, 16, 16))
+	for i := range a.Pix {
+		a.Pix[i], b.Pix[i] = 128, 130
+	}
+	if d, err := ButteraugliLite(a, b); err != nil || math.Abs(d-1) > 1e-9 {
+		t.Errorf("expected a distance of 1 between grays of 128 and 130 but instead got %v and %v", d, err)
+	}
+
+	// heavier noise is more visible
+	prev := 0.
+	for _, amplitude := range []int{2, 8, 64} {
+		d, err := ButteraugliLite(ref, noisy(ref, amplitude))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d <= prev {
+			t.Errorf("expected noise of amplitude %d to be further than %v but instead got %v", amplitude, prev, d)
+		}
+		prev = d
+	}
+
+	if _, err := ButteraugliLite(ref, image.NewNRGBA(image.Rect(0, 0, 64, 32))); !errors.Is(err, ErrSize) {
+		t.Errorf("expected ErrSize but instead got %v", err)
+	}
+}
+
+func TestButteraugliLiteMasking(t *testing.T) {
+	// flat gray on the left, a fine checkerboard on the right
+	ref := image.NewGray(image.Rect(0, 0, 64, 32))
+	for y := range 32 {
+		for x := range 64 {
+			v := uint8(128)
+			if x >= 32 {
+				v = uint8(64 + 128*((x+y)%2))
+			}
+			ref.Pix[y*ref.Stride+x] = v
+		}
+	}
+
+	// the same noise is added to an 8x8 patch in the middle of either half
+	withNoise := func(x0 int) *image.Gray {
+		img := image.NewGray(ref.Rect)
+		copy(img.Pix, ref.Pix)
+		seed := uint32(1)
+		for y := 12; y < 20; y++ {
+			for x := x0; x < x0+8; x++ {
+				seed = seed*1664525 + 1013904223
+				img.Pix[y*img.Stride+x] += uint8(int(seed>>24)%13 - 6)
+			}
+		}
+		return img
+	}
+	flat, textured := withNoise(12), withNoise(44)
+
+	// both have the same squared error, but texture hides the noise
+	mseFlat, err := MSE(ref, flat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mseTextured, err := MSE(ref, textured)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(mseFlat-mseTextured) > 1e-9 {
+		t.Fatalf("expected the same MSE but instead got %v and %v", mseFlat, mseTextured)
+	}
+
+	dFlat, err := ButteraugliLite(ref, flat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dTextured, err := ButteraugliLite(ref, textured)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dTextured >= dFlat/2 {
+		t.Errorf("expected noise on texture to be less than half as visible as on a flat area (%v) but instead got %v", dFlat, dTextured)
+	}
+}
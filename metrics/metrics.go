@@ -0,0 +1,298 @@
+// Package metrics measures how close an image is to a reference of the same size:
+// mean squared error, PSNR, SSIM, MS-SSIM and a lite take on the perceptual distance of Butteraugli.
+//
+// Color values are compared on a 0 to 255 scale whatever the bit depth of the images,
+// and transparent pixels are compared as if drawn over black.
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+// ErrSize is returned when the images don't have the same size
+var ErrSize = errors.New("metrics: images of different sizes")
+
+// Result holds every metric of an image against a reference
+type Result struct {
+	MSE    float64 `json:"mse"`     // mean squared error of the RGB values, 0 for identical images
+	PSNR   float64 `json:"psnr"`    // peak signal-to-noise ratio of the RGB values in dB, +Inf for identical images
+	SSIM   float64 `json:"ssim"`    // structural similarity of the luma, 1 for identical images
+	MSSSIM float64 `json:"ms_ssim"` // multi-scale structural similarity of the luma, 1 for identical images, NaN when not applicable
+
+	ButteraugliLite float64 `json:"butteraugli_lite"` // perceptual distance of the colors, 0 for identical images, see ButteraugliLite
+}
+
+func (r Result) String() string {
+	msssim := "n/a"
+	if !math.IsNaN(r.MSSSIM) {
+		msssim = fmt.Sprintf("%.4f", r.MSSSIM)
+	}
+	return fmt.Sprintf("MSE: %.4f, PSNR: %.2f dB, SSIM: %.4f, MS-SSIM: %s, Butteraugli-lite: %.4f", r.MSE, r.PSNR, r.SSIM, msssim, r.ButteraugliLite)
+}
+
+// Compare computes every metric of img against ref
+// MS-SSIM is NaN for images too small for it, see MSSSIM, the other metrics are computed whatever the size
+func Compare(ref, img image.Image) (Result, error) {
+	mse, err := MSE(ref, img)
+	if err != nil {
+		return Result{}, err
+	}
+	ssim, err := SSIM(ref, img)
+	if err != nil {
+		return Result{}, err
+	}
+	msssim := math.NaN()
+	if fitsMSSSIM(ref.Bounds().Size()) {
+		if msssim, err = MSSSIM(ref, img); err != nil {
+			return Result{}, err
+		}
+	}
+	distance, err := ButteraugliLite(ref, img)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{MSE: mse, PSNR: psnr(mse), SSIM: ssim, MSSSIM: msssim, ButteraugliLite: distance}, nil
+}
+
+// MSE returns the mean squared error of the RGB values of img against ref
+func MSE(ref, img image.Image) (float64, error) {
+	if err := checkSize(ref, img); err != nil {
+		return 0, err
+	}
+
+	a, b := rgb(ref), rgb(img)
+
+	var sum float64
+	for c := range a {
+		for i := range a[c] {
+			d := a[c][i] - b[c][i]
+			sum += d * d
+		}
+	}
+
+	return sum / float64(3*len(a[0])), nil
+}
+
+// PSNR returns the peak signal-to-noise ratio of the RGB values of img against ref in dB, higher is closer
+// identical images return +Inf
+func PSNR(ref, img image.Image) (float64, error) {
+	mse, err := MSE(ref, img)
+	if err != nil {
+		return 0, err
+	}
+
+	return psnr(mse), nil
+}
+
+func psnr(mse float64) float64 {
+	return 10 * math.Log10(255*255/mse)
+}
+
+// SSIM returns the mean structural similarity of the luma of img against ref, from -1 to 1, higher is closer
+// statistics are weighted by a gaussian window of standard deviation 1.5, as in Wang et al. (2004)
+func SSIM(ref, img image.Image) (float64, error) {
+	if err := checkSize(ref, img); err != nil {
+		return 0, err
+	}
+
+	size := ref.Bounds().Size()
+	l, cs := ssim(luma(ref), luma(img), size.X, size.Y)
+
+	return l * cs, nil
+}
+
+// weights of the scales of MS-SSIM, from the finest to the coarsest, see Wang et al. (2003)
+var scaleWeights = []float64{0.0448, 0.2856, 0.3001, 0.2363, 0.1333}
+
+// MSSSIM returns the multi-scale structural similarity of the luma of img against ref, from 0 to 1, higher is closer
+// the images are halved 4 times, so they must be at least 16x16
+func MSSSIM(ref, img image.Image) (float64, error) {
+	if err := checkSize(ref, img); err != nil {
+		return 0, err
+	}
+
+	size := ref.Bounds().Size()
+	if !fitsMSSSIM(size) {
+		smallest := 1 << (len(scaleWeights) - 1)
+		return 0, fmt.Errorf("metrics: %dx%d images are too small for MS-SSIM, expected at least %dx%d", size.X, size.Y, smallest, smallest)
+	}
+
+	a, b := luma(ref), luma(img)
+	w, h := size.X, size.Y
+
+	result := 1.
+	for i, weight := range scaleWeights {
+		l, cs := ssim(a, b, w, h)
+
+		// contrast and structure at every scale, luminance only at the coarsest one
+		// negative similarities are clamped, as they have no fractional power
+		result *= math.Pow(math.Max(cs, 0), weight)
+		if i == len(scaleWeights)-1 {
+			result *= math.Pow(math.Max(l, 0), weight)
+			break
+		}
+
+		a, _, _ = halve(a, w, h)
+		b, w, h = halve(b, w, h)
+	}
+
+	return result, nil
+}
+
+// reports whether images of size can be halved for every scale of MS-SSIM
+func fitsMSSSIM(size image.Point) bool {
+	smallest := 1 << (len(scaleWeights) - 1)
+	return size.X >= smallest && size.Y >= smallest
+}
+
+// constants keeping the SSIM terms stable when means and variances are close to 0
+const (
+	c1 = (0.01 * 255) * (0.01 * 255)
+	c2 = (0.03 * 255) * (0.03 * 255)
+)
+
+// returns the mean luminance similarity and the mean contrast-structure similarity of the w x h planes x and y
+func ssim(x, y []float64, w, h int) (l, cs float64) {
+	xx := make([]float64, len(x))
+	yy := make([]float64, len(x))
+	xy := make([]float64, len(x))
+	for i := range x {
+		xx[i] = x[i] * x[i]
+		yy[i] = y[i] * y[i]
+		xy[i] = x[i] * y[i]
+	}
+
+	muX, muY := blur(x, w, h), blur(y, w, h)
+	sXX, sYY, sXY := blur(xx, w, h), blur(yy, w, h), blur(xy, w, h)
+
+	for i := range x {
+		mX, mY := muX[i], muY[i]
+		varX, varY, cov := sXX[i]-mX*mX, sYY[i]-mY*mY, sXY[i]-mX*mY
+
+		l += (2*mX*mY + c1) / (mX*mX + mY*mY + c1)
+		cs += (2*cov + c2) / (varX + varY + c2)
+	}
+
+	n := float64(len(x))
+	return l / n, cs / n
+}
+
+// gaussian window of SSIM, 11 pixels wide with a standard deviation of 1.5
+var window = gaussian(1.5)
+
+// returns a normalized gaussian window of standard deviation sigma, reaching 3 sigmas on each side
+func gaussian(sigma float64) []float64 {
+	r := int(math.Ceil(3 * sigma))
+
+	k := make([]float64, 2*r+1)
+	var sum float64
+	for i := range k {
+		d := float64(i - r)
+		k[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += k[i]
+	}
+	for i := range k {
+		k[i] /= sum
+	}
+
+	return k
+}
+
+// returns the w x h plane p convolved with window horizontally and vertically, clamping at the edges
+func blur(p []float64, w, h int) []float64 {
+	return convolve(p, w, h, window)
+}
+
+// returns the w x h plane p convolved with kernel horizontally and vertically, clamping at the edges
+func convolve(p []float64, w, h int, kernel []float64) []float64 {
+	r := len(kernel) / 2
+
+	tmp := make([]float64, len(p))
+	for y := range h {
+		row := p[y*w : (y+1)*w]
+		for x := range w {
+			var sum float64
+			for i, k := range kernel {
+				sum += k * row[min(max(x+i-r, 0), w-1)]
+			}
+			tmp[y*w+x] = sum
+		}
+	}
+
+	out := make([]float64, len(p))
+	for y := range h {
+		for x := range w {
+			var sum float64
+			for i, k := range kernel {
+				sum += k * tmp[min(max(y+i-r, 0), h-1)*w+x]
+			}
+			out[y*w+x] = sum
+		}
+	}
+
+	return out
+}
+
+// averages the 2x2 blocks of the w x h plane p, dropping the last row and column of odd sizes
+// returns the halved plane and its size
+func halve(p []float64, w, h int) ([]float64, int, int) {
+	hW, hH := w/2, h/2
+
+	out := make([]float64, hW*hH)
+	for y := range hH {
+		for x := range hW {
+			i := 2*y*w + 2*x
+			out[y*hW+x] = (p[i] + p[i+1] + p[i+w] + p[i+w+1]) / 4
+		}
+	}
+
+	return out, hW, hH
+}
+
+func checkSize(ref, img image.Image) error {
+	a, b := ref.Bounds().Size(), img.Bounds().Size()
+	if a != b {
+		return fmt.Errorf("%w: %dx%d reference, %dx%d image", ErrSize, a.X, a.Y, b.X, b.Y)
+	}
+	if a.X == 0 || a.Y == 0 {
+		return errors.New("metrics: empty images")
+	}
+
+	return nil
+}
+
+// returns the red, green and blue planes of img on a 0 to 255 scale, row by row
+func rgb(img image.Image) [3][]float64 {
+	b := img.Bounds()
+
+	var planes [3][]float64
+	for c := range planes {
+		planes[c] = make([]float64, 0, b.Dx()*b.Dy())
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			planes[0] = append(planes[0], float64(r)/257)
+			planes[1] = append(planes[1], float64(g)/257)
+			planes[2] = append(planes[2], float64(bl)/257)
+		}
+	}
+
+	return planes
+}
+
+// returns the luma plane of img on a 0 to 255 scale, row by row, with the weights of ITU-R BT.601
+func luma(img image.Image) []float64 {
+	p := rgb(img)
+
+	y := p[0]
+	for i := range y {
+		y[i] = 0.299*p[0][i] + 0.587*p[1][i] + 0.114*p[2][i]
+	}
+
+	return y
+}
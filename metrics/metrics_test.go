@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"errors"
+	"image"
+	"math"
+	"testing"
+)
+
+// 64x64 gradient with a few diagonal stripes, so that it has structure at every scale
+func reference() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := range 64 {
+		for x := range 64 {
+			v := uint8(2*x + y)
+			if (x+y)/8%2 == 0 {
+				v /= 2
+			}
+			i := img.PixOffset(x, y)
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = v, 255-v, v/2, 255
+		}
+	}
+	return img
+}
+
+// returns a copy of img with seeded noise of amplitude in color values added
+func noisy(img *image.NRGBA, amplitude int) *image.NRGBA {
+	dst := image.NewNRGBA(img.Rect)
+	copy(dst.Pix, img.Pix)
+
+	seed := uint32(1)
+	for i := range dst.Pix {
+		if i%4 == 3 {
+			continue
+		}
+		seed = seed*1664525 + 1013904223
+		v := int(dst.Pix[i]) + int(seed>>24)%(2*amplitude+1) - amplitude
+		dst.Pix[i] = uint8(min(max(v, 0), 255))
+	}
+	return dst
+}
+
+func TestIdentical(t *testing.T) {
+	ref := reference()
+
+	r, err := Compare(ref, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.MSE != 0 || !math.IsInf(r.PSNR, 1) {
+		t.Errorf("expected an MSE of 0 and a PSNR of +Inf but instead got %v and %v", r.MSE, r.PSNR)
+	}
+	if math.Abs(r.SSIM-1) > 1e-9 || math.Abs(r.MSSSIM-1) > 1e-9 {
+		t.Errorf("expected an SSIM and MS-SSIM of 1 but instead got %v and %v", r.SSIM, r.MSSSIM)
+	}
+}
+
+func TestMSE(t *testing.T) {
+	ref := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := range ref.Pix {
+		ref.Pix[i] = 100
+		img.Pix[i] = 110
+		// alpha is not compared, but premultiplies the colors
+		if i%4 == 3 {
+			ref.Pix[i], img.Pix[i] = 255, 255
+		}
+	}
+
+	mse, err := MSE(ref, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(mse-100) > 1e-9 {
+		t.Errorf("expected an MSE of 100 but instead got %v", mse)
+	}
+
+	psnr, err := PSNR(ref, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := 10 * math.Log10(255*255/100.); math.Abs(psnr-expected) > 1e-9 {
+		t.Errorf("expected a PSNR of %.4fdB but instead got %.4fdB", expected, psnr)
+	}
+}
+
+func TestNoise(t *testing.T) {
+	ref := reference()
+
+	// every metric must prefer light noise over heavy noise
+	light, err := Compare(ref, noisy(ref, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	heavy, err := Compare(ref, noisy(ref, 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if light.MSE >= heavy.MSE || light.PSNR <= heavy.PSNR {
+		t.Errorf("expected light noise to have a lower MSE and a higher PSNR than heavy noise but instead got %v and %v", light, heavy)
+	}
+	if light.SSIM <= heavy.SSIM || light.MSSSIM <= heavy.MSSSIM {
+		t.Errorf("expected light noise to have a higher SSIM and MS-SSIM than heavy noise but instead got %v and %v", light, heavy)
+	}
+	if light.SSIM >= 1 || heavy.SSIM <= -1 || light.MSSSIM >= 1 || heavy.MSSSIM < 0 {
+		t.Errorf("expected similarities within their ranges but instead got %v and %v", light, heavy)
+	}
+}
+
+// returns a copy of img moved right by dx pixels, repeating its first column on the left
+func shifted(img *image.NRGBA, dx int) *image.NRGBA {
+	dst := image.NewNRGBA(img.Rect)
+	for y := range img.Rect.Dy() {
+		for x := range img.Rect.Dx() {
+			dst.SetNRGBA(x, y, img.NRGBAAt(max(x-dx, 0), y))
+		}
+	}
+	return dst
+}
+
+func TestShift(t *testing.T) {
+	ref := reference()
+
+	// the further an image is shifted, the less similar its structure is
+	prev := Result{SSIM: 1, MSSSIM: 1}
+	for _, dx := range []int{1, 2, 4} {
+		r, err := Compare(ref, shifted(ref, dx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.SSIM >= prev.SSIM || r.MSSSIM >= prev.MSSSIM {
+			t.Errorf("expected a shift of %d pixels to score a lower SSIM and MS-SSIM than %v but instead got %v", dx, prev, r)
+		}
+		prev = r
+	}
+}
+
+func TestSize(t *testing.T) {
+	ref := reference()
+
+	if _, err := Compare(ref, image.NewNRGBA(image.Rect(0, 0, 64, 32))); !errors.Is(err, ErrSize) {
+		t.Errorf("expected ErrSize but instead got %v", err)
+	}
+
+	// MS-SSIM needs 5 scales, the others work on any size
+	small := image.NewGray(image.Rect(0, 0, 8, 8))
+	if _, err := SSIM(small, small); err != nil {
+		t.Errorf("expected SSIM of 8x8 images but instead got %v", err)
+	}
+	if _, err := MSSSIM(small, small); err == nil {
+		t.Error("expected an error for MS-SSIM of 8x8 images but instead got none")
+	}
+
+	// Compare still returns the other metrics
+	r, err := Compare(small, small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.MSE != 0 || math.Abs(r.SSIM-1) > 1e-9 || !math.IsNaN(r.MSSSIM) {
+		t.Errorf("expected an MSE of 0, an SSIM of 1 and an MS-SSIM of NaN but instead got %v", r)
+	}
+}
@@ -0,0 +1,262 @@
+// Package parallel spreads per-pixel work of an image over goroutines.
+package parallel
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Partition decides how the pixels of a w x h image are split between workers
+type Partition int
+
+const (
+	// Flat splits the flat pixel index range [0, w*h) into one even range per worker
+	Flat Partition = iota
+	// RowBand splits the image into bands of whole rows that workers pick up one after another
+	RowBand
+)
+
+// number of bands handed out per worker with RowBand, so that a slow band doesn't stall the others
+const bandsPerWorker = 4
+
+// number of progress reports per run at most, one per percent
+const progressSteps = 100
+
+// Config describes how Run spreads the work
+type Config struct {
+	Workers   int       // number of goroutines, defaults to runtime.NumCPU() when 0
+	Pool      *Pool     // shared pool to run on instead of spawning goroutines, Workers is ignored when set
+	Partition Partition // Flat | RowBand
+
+	// optional callback receiving the number of completed rows out of total rows
+	// calls are serialized and throttled to one per percent of rows, the last call always has done == total
+	Progress func(done, total int)
+}
+
+// Pool is a fixed set of goroutines that can be shared by many Run calls at once,
+// so that processing several images at the same time doesn't oversubscribe the CPU
+type Pool struct {
+	workers int
+	tasks   chan func()
+	wg      sync.WaitGroup
+}
+
+// NewPool starts a pool with the given number of goroutines, defaults to runtime.NumCPU() when workers <= 0
+func NewPool(workers int) *Pool {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	p := &Pool{
+		workers: workers,
+		tasks:   make(chan func()),
+	}
+
+	p.wg.Add(workers)
+	for range workers {
+		go func() {
+			defer p.wg.Done()
+			for task := range p.tasks {
+				task()
+			}
+		}()
+	}
+
+	return p
+}
+
+// Workers returns the number of goroutines in the pool
+func (p *Pool) Workers() int {
+	return p.workers
+}
+
+// Close stops the pool after the submitted tasks are done
+// the pool must not be used after Close
+func (p *Pool) Close() {
+	close(p.tasks)
+	p.wg.Wait()
+}
+
+// NumWorkers returns how many goroutines Run uses in concurrency mode
+func (c Config) NumWorkers() int {
+	if c.Pool != nil {
+		return c.Pool.Workers()
+	}
+	if c.Workers > 0 {
+		return c.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// Run calls operate on ranges [start, end) of the flat pixel index of a w x h image until all pixels are covered
+// without concurrency, operate is called once with the whole range
+// returns the first error returned by operate, the context passed to the remaining calls is cancelled then
+// returns ctx.Err() when ctx is done while waiting for the workers of Config.Pool
+func Run(ctx context.Context, concurrency bool, c Config, w, h int, operate func(ctx context.Context, start, end int) error) error {
+	if c.Progress != nil && w > 0 && h > 0 {
+		operate = newProgress(c.Progress, w, h).wrap(operate)
+	}
+
+	if !concurrency {
+		return operate(ctx, 0, w*h)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := c.NumWorkers()
+
+	// list of jobs, each of them takes care of its own part of the image
+	var jobs []func() error
+
+	if c.Partition == RowBand {
+		bands := min(h, workers*bandsPerWorker)
+		var next atomic.Int64
+
+		job := func() error {
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= bands {
+					return nil
+				}
+				if err := operate(ctx, i*h/bands*w, (i+1)*h/bands*w); err != nil {
+					return err
+				}
+			}
+		}
+		for range min(workers, bands) {
+			jobs = append(jobs, job)
+		}
+	} else {
+		total := w * h
+		for i := range workers {
+			jobs = append(jobs, func() error {
+				return operate(ctx, i*total/workers, (i+1)*total/workers)
+			})
+		}
+	}
+
+	errs := make(chan error, len(jobs))
+
+	// a pool busy with other runs hands its workers out as they free up, unless ctx is done first
+	var err error
+	submitted := 0
+	for _, job := range jobs {
+		task := func() { errs <- job() }
+		if c.Pool != nil {
+			select {
+			case c.Pool.tasks <- task:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		} else {
+			go task()
+		}
+		if err != nil {
+			break
+		}
+		submitted++
+	}
+
+	// drain the channel, the submitted tasks are running and stop at the cancellation of ctx
+	for range submitted {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+			cancel()
+		}
+	}
+	// all done
+
+	return err
+}
+
+// RunRows is Run calling row with the part [x0, x1) of the row y of every row its ranges cover
+// ctx is checked before every row, ctx.Err() is returned once it is done
+func RunRows(ctx context.Context, concurrency bool, c Config, w, h int, row func(y, x0, x1 int)) error {
+	return Run(ctx, concurrency, c, w, h, func(ctx context.Context, start, end int) error {
+		for start < end {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			y, x0 := start/w, start%w
+			x1 := min(w, x0+end-start)
+			row(y, x0, x1)
+
+			start += x1 - x0
+		}
+		return nil
+	})
+}
+
+// RunPixels is RunRows calling pixel for every pixel (x, y) of the rows
+func RunPixels(ctx context.Context, concurrency bool, c Config, w, h int, pixel func(x, y int)) error {
+	return RunRows(ctx, concurrency, c, w, h, func(y, x0, x1 int) {
+		for x := x0; x < x1; x++ {
+			pixel(x, y)
+		}
+	})
+}
+
+// Throttle returns a function passing its calls on to report once per percent of total at most,
+// and always when done == total, so that work not split into rows can report progress like Run
+// calls are serialized, done is expected to grow from one call to the next
+func Throttle(report func(done, total int)) func(done, total int) {
+	var mu sync.Mutex
+	last := 0 // done at the last report
+
+	return func(done, total int) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		step := (total + progressSteps - 1) / progressSteps
+		if done == total || done-last >= step {
+			last = done
+			report(done, total)
+		}
+	}
+}
+
+// keeps track of completed pixels and reports them as completed rows
+type progress struct {
+	mu     sync.Mutex
+	report func(done, total int) // throttled
+	w, h   int
+	pixels int // number of completed pixels
+}
+
+func newProgress(report func(done, total int), w, h int) *progress {
+	return &progress{
+		report: Throttle(report),
+		w:      w,
+		h:      h,
+	}
+}
+
+// returns operate that works row by row and reports after each of them
+func (p *progress) wrap(operate func(ctx context.Context, start, end int) error) func(ctx context.Context, start, end int) error {
+	return func(ctx context.Context, start, end int) error {
+		for start < end {
+			// end of the row containing start
+			rowEnd := min(end, (start/p.w+1)*p.w)
+
+			if err := operate(ctx, start, rowEnd); err != nil {
+				return err
+			}
+			p.add(rowEnd - start)
+
+			start = rowEnd
+		}
+		return nil
+	}
+}
+
+func (p *progress) add(pixels int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pixels += pixels
+	p.report(p.pixels/p.w, p.h)
+}
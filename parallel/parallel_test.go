@@ -0,0 +1,202 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	pool := NewPool(3)
+	defer pool.Close()
+
+	w, h := 7, 13
+
+	configs := map[string]Config{
+		"default":         {},
+		"fixed workers":   {Workers: 5},
+		"row band":        {Workers: 2, Partition: RowBand},
+		"pool":            {Pool: pool},
+		"pool + row band": {Pool: pool, Partition: RowBand},
+		"more than rows":  {Workers: 32, Partition: RowBand},
+	}
+
+	for name, c := range configs {
+		for _, concurrency := range []bool{false, true} {
+			// every pixel has to be visited exactly once
+			visits := make([]atomic.Int32, w*h)
+
+			err := Run(context.Background(), concurrency, c, w, h, func(ctx context.Context, start, end int) error {
+				if c.Partition == RowBand && concurrency && (start%w != 0 || end%w != 0) {
+					t.Errorf("%s: expected whole rows but instead got [%d, %d)", name, start, end)
+				}
+				for i := start; i < end; i++ {
+					visits[i].Add(1)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := range visits {
+				if v := visits[i].Load(); v != 1 {
+					t.Errorf("%s: expected pixel %d to be visited once but instead got %d", name, i, v)
+				}
+			}
+		}
+	}
+}
+
+func TestRunPixels(t *testing.T) {
+	w, h := 7, 13
+
+	for _, c := range []Config{{Workers: 5}, {Workers: 2, Partition: RowBand}} {
+		// every pixel has to be visited exactly once, from rows within the image
+		visits := make([]atomic.Int32, w*h)
+
+		err := RunPixels(context.Background(), true, c, w, h, func(x, y int) {
+			if x < 0 || x >= w || y < 0 || y >= h {
+				t.Errorf("expected a pixel of the %dx%d image but instead got (%d, %d)", w, h, x, y)
+				return
+			}
+			visits[y*w+x].Add(1)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range visits {
+			if v := visits[i].Load(); v != 1 {
+				t.Errorf("expected pixel %d to be visited once but instead got %d", i, v)
+			}
+		}
+	}
+
+	// rows are not started once ctx is done
+	ctx, cancel := context.WithCancel(context.Background())
+	rows := 0
+	err := RunRows(ctx, false, Config{}, w, h, func(y, x0, x1 int) {
+		rows++
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) || rows != 1 {
+		t.Errorf("expected context.Canceled after 1 row but instead got %v after %d", err, rows)
+	}
+}
+
+func TestRunError(t *testing.T) {
+	pool := NewPool(2)
+	defer pool.Close()
+
+	errFailed := errors.New("failed")
+
+	for _, c := range []Config{{Workers: 4}, {Pool: pool, Partition: RowBand}} {
+		err := Run(context.Background(), true, c, 10, 10, func(ctx context.Context, start, end int) error {
+			if start == 0 {
+				return errFailed
+			}
+			return ctx.Err()
+		})
+		if !errors.Is(err, errFailed) {
+			t.Errorf("expected errFailed but instead got %v", err)
+		}
+	}
+}
+
+func TestRunCancelFullPool(t *testing.T) {
+	pool := NewPool(1)
+	defer pool.Close()
+
+	// another run keeps the only worker busy
+	release := make(chan struct{})
+	busy := make(chan struct{})
+	go Run(context.Background(), true, Config{Pool: pool}, 1, 1, func(ctx context.Context, start, end int) error {
+		close(busy)
+		<-release
+		return nil
+	})
+	<-busy
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	var calls atomic.Int32
+	go func() {
+		done <- Run(ctx, true, Config{Pool: pool}, 10, 10, func(ctx context.Context, start, end int) error {
+			calls.Add(1)
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded but instead got %v", err)
+		}
+		if n := calls.Load(); n != 0 {
+			t.Errorf("expected no calls of operate but instead got %d", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Run to return once its context is done but instead it waited for the pool")
+	}
+}
+
+func TestRunProgress(t *testing.T) {
+	w, h := 3, 250
+
+	for _, c := range []Config{{Workers: 4}, {Workers: 3, Partition: RowBand}} {
+		for _, concurrency := range []bool{false, true} {
+			var reports []int
+
+			c.Progress = func(done, total int) {
+				if total != h {
+					t.Errorf("expected total to be %d but instead got %d", h, total)
+				}
+				reports = append(reports, done)
+			}
+
+			err := Run(context.Background(), concurrency, c, w, h, func(ctx context.Context, start, end int) error {
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(reports) == 0 || len(reports) > progressSteps+1 {
+				t.Fatalf("expected between 1 and %d reports but instead got %d", progressSteps+1, len(reports))
+			}
+			for i := 1; i < len(reports); i++ {
+				if reports[i] <= reports[i-1] {
+					t.Errorf("expected reports to increase but instead got %v", reports)
+					break
+				}
+			}
+			if last := reports[len(reports)-1]; last != h {
+				t.Errorf("expected the last report to be %d but instead got %d", h, last)
+			}
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	var reports []int
+	report := Throttle(func(done, total int) {
+		reports = append(reports, done)
+	})
+
+	for done := 1; done <= 1000; done++ {
+		report(done, 1000)
+	}
+
+	if len(reports) != progressSteps {
+		t.Errorf("expected %d reports but instead got %d", progressSteps, len(reports))
+	}
+	if last := reports[len(reports)-1]; last != 1000 {
+		t.Errorf("expected the last report to be 1000 but instead got %d", last)
+	}
+}
@@ -0,0 +1,83 @@
+// Package pipeline chains image operations, such as crop, resize, rotate and sharpen, in a declared order.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"time"
+)
+
+// Step is one operation of a Pipeline
+type Step interface {
+	// name of the step in errors and timings, such as "resize"
+	Name() string
+	// checks the parameters of the step for an input of size, and returns the size of its output
+	Validate(size image.Point) (image.Point, error)
+	// runs the step on src, whose size has passed Validate
+	Apply(ctx context.Context, src image.Image, concurrency bool) (image.Image, error)
+}
+
+// Pipeline runs its steps one after another, each of them on the output of the previous one
+type Pipeline struct {
+	steps []Step
+}
+
+// initialize Pipeline running steps in the order they are passed
+func New(steps ...Step) *Pipeline {
+	return &Pipeline{steps: steps}
+}
+
+// Timing describes a finished step
+type Timing struct {
+	Step     string        // name of the step
+	Input    image.Point   // size of the input of the step
+	Output   image.Point   // size of the output of the step
+	Duration time.Duration // time spent in the step
+}
+
+func (t Timing) String() string {
+	return fmt.Sprintf("%s %dx%d -> %dx%d took %v to run", t.Step, t.Input.X, t.Input.Y, t.Output.X, t.Output.Y, t.Duration)
+}
+
+// Validate checks every step for a source of size, without processing anything, and returns the size of the output
+// the error names the first step that fails
+func (p *Pipeline) Validate(size image.Point) (image.Point, error) {
+	for i, step := range p.steps {
+		out, err := step.Validate(size)
+		if err != nil {
+			return image.Point{}, fmt.Errorf("step %d (%s): %w", i+1, step.Name(), err)
+		}
+		size = out
+	}
+
+	return size, nil
+}
+
+// Run validates the steps for src, then runs them on it
+// returns the output of the last step and the timings of the steps, src itself when there are no steps
+func (p *Pipeline) Run(ctx context.Context, src image.Image, concurrency bool) (image.Image, []Timing, error) {
+	if _, err := p.Validate(src.Bounds().Size()); err != nil {
+		return nil, nil, err
+	}
+
+	timings := make([]Timing, 0, len(p.steps))
+	for i, step := range p.steps {
+		start := time.Now()
+
+		out, err := step.Apply(ctx, src, concurrency)
+		if err != nil {
+			return nil, timings, fmt.Errorf("step %d (%s): %w", i+1, step.Name(), err)
+		}
+
+		timings = append(timings, Timing{
+			Step:     step.Name(),
+			Input:    src.Bounds().Size(),
+			Output:   out.Bounds().Size(),
+			Duration: time.Since(start),
+		})
+		src = out
+	}
+
+	return src, timings, nil
+}
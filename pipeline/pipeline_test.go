@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"gthub.com/obzva/image-resize/filter"
+	"gthub.com/obzva/image-resize/interpolator"
+)
+
+// step counting how many times it was applied, passing its input on
+type counter struct {
+	applied int
+}
+
+func (c *counter) Name() string {
+	return "counter"
+}
+
+func (c *counter) Validate(size image.Point) (image.Point, error) {
+	return size, nil
+}
+
+func (c *counter) Apply(ctx context.Context, src image.Image, concurrency bool) (image.Image, error) {
+	c.applied++
+	return src, nil
+}
+
+func TestPipeline(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 60, 40))
+	for y := range 40 {
+		for x := range 60 {
+			src.SetNRGBA(x, y, color.NRGBA{uint8(4 * x), uint8(6 * y), 90, 255})
+		}
+	}
+
+	c := &counter{}
+	p := New(
+		Crop{Rect: image.Rect(10, 0, 50, 40)},
+		Resize{Width: 20, Method: "bilinear"},
+		c,
+		Flip{Op: interpolator.Transpose},
+		Rotate{Rotation: interpolator.Rotation{Degrees: 90, Expand: true}, Method: "bicubic"},
+		Pad{Top: 1, Right: 2, Bottom: 3, Left: 4, Background: color.White},
+		Sharpen{Sharpening: filter.Sharpening{Radius: 1, Amount: 0.5}},
+		Blur{Sigma: 0.5},
+		Convert{Model: ModelGray},
+	)
+
+	size, err := p.Validate(src.Bounds().Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != image.Pt(26, 24) {
+		t.Errorf("expected a 26x24 output but instead got %v", size)
+	}
+
+	out, timings, err := p.Run(context.Background(), src, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := out.(*image.Gray); !ok || out.Bounds().Size() != size {
+		t.Errorf("expected a %v gray image but instead got %T of %v", size, out, out.Bounds().Size())
+	}
+	if c.applied != 1 {
+		t.Errorf("expected the custom step to be applied once but instead got %d", c.applied)
+	}
+
+	expected := []struct {
+		step string
+		out  image.Point
+	}{
+		{"crop", image.Pt(40, 40)},
+		{"resize", image.Pt(20, 20)},
+		{"counter", image.Pt(20, 20)},
+		{"flip", image.Pt(20, 20)},
+		{"rotate", image.Pt(20, 20)},
+		{"pad", image.Pt(26, 24)},
+		{"sharpen", image.Pt(26, 24)},
+		{"blur", image.Pt(26, 24)},
+		{"convert", image.Pt(26, 24)},
+	}
+	if len(timings) != len(expected) {
+		t.Fatalf("expected %d timings but instead got %d", len(expected), len(timings))
+	}
+	for i, e := range expected {
+		if timings[i].Step != e.step || timings[i].Output != e.out {
+			t.Errorf("expected %s with a %v output as step %d but instead got %v", e.step, e.out, i+1, timings[i])
+		}
+	}
+
+	// the crop keeps the pixels it covers, the padding is white
+	cropped, _, err := New(Crop{Rect: image.Rect(10, 5, 12, 6)}, Pad{Left: 1, Background: color.White}).Run(context.Background(), src, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x, want := range []color.NRGBA{{255, 255, 255, 255}, src.NRGBAAt(10, 5), src.NRGBAAt(11, 5)} {
+		if got := color.NRGBAModel.Convert(cropped.At(x, 0)); got != want {
+			t.Errorf("expected %v at (%d, 0) but instead got %v", want, x, got)
+		}
+	}
+}
+
+func TestPipelineValidate(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+
+	cases := map[string]struct {
+		steps []Step
+		fails int // index of the failing step
+	}{
+		"crop outside":        {[]Step{Crop{Rect: image.Rect(0, 0, 20, 8)}}, 0},
+		"unknown method":      {[]Step{Crop{Rect: image.Rect(0, 0, 8, 8)}, Resize{Width: 4, Method: "lanczos"}}, 1},
+		"no size":             {[]Step{Resize{Method: "bilinear"}}, 0},
+		"pixel-art scale":     {[]Step{Resize{Width: 16, Height: 8, Method: "xbr2x"}}, 0},
+		"pixel-art rotation":  {[]Step{Rotate{Rotation: interpolator.Rotation{Degrees: 30}, Method: "xbr2x"}}, 0},
+		"unknown edge":        {[]Step{Resize{Width: 4, Method: "bilinear", Options: interpolator.Options{Edge: "mirror"}}}, 0},
+		"rotation edge":       {[]Step{Rotate{Rotation: interpolator.Rotation{Degrees: 30}, Method: "bilinear", Options: interpolator.Options{Edge: "mirror"}}}, 0},
+		"cubic parameters":    {[]Step{Resize{Width: 4, Method: "cubic", Options: interpolator.Options{B: 2, C: -1}}}, 0},
+		"unknown flip":        {[]Step{Flip{Op: "diagonal"}}, 0},
+		"negative padding":    {[]Step{Pad{Top: -1}}, 0},
+		"no sharpening":       {[]Step{Sharpen{}}, 0},
+		"unknown color model": {[]Step{Convert{Model: "cmyk"}}, 0},
+		"crop after resize":   {[]Step{Resize{Width: 4, Method: "bilinear"}, Crop{Rect: image.Rect(0, 0, 4, 4)}}, 1},
+	}
+
+	for name, tc := range cases {
+		// nothing runs when any step is invalid
+		c := &counter{}
+		_, _, err := New(append([]Step{c}, tc.steps...)...).Run(context.Background(), src, true)
+		if err == nil {
+			t.Errorf("%s: expected an error but instead got nil", name)
+			continue
+		}
+
+		// the counter is step 1
+		step := tc.steps[tc.fails]
+		if want := fmt.Sprintf("step %d (%s): ", tc.fails+2, step.Name()); !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%s: expected an error starting with %q but instead got %q", name, want, err)
+		}
+		if c.applied != 0 {
+			t.Errorf("%s: expected no step to run but instead the first one ran %d time(s)", name, c.applied)
+		}
+	}
+
+	_, err := New(Resize{Width: 16, Height: 16, Method: "scale2x"}).Validate(src.Bounds().Size())
+	if !errors.Is(err, interpolator.ErrScale) {
+		t.Errorf("expected interpolator.ErrScale but instead got %v", err)
+	}
+}
@@ -0,0 +1,288 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"gthub.com/obzva/image-resize/filter"
+	"gthub.com/obzva/image-resize/interpolator"
+)
+
+// color models of Convert
+const (
+	ModelNRGBA   = "nrgba"   // 8 bits per channel with alpha
+	ModelNRGBA64 = "nrgba64" // 16 bits per channel with alpha
+	ModelGray    = "gray"    // 8-bit luma
+	ModelGray16  = "gray16"  // 16-bit luma
+)
+
+// Crop keeps the pixels of Rect, relative to the top-left corner of the input
+type Crop struct {
+	Rect image.Rectangle
+}
+
+func (c Crop) Name() string {
+	return "crop"
+}
+
+func (c Crop) Validate(size image.Point) (image.Point, error) {
+	if c.Rect.Empty() {
+		return image.Point{}, fmt.Errorf("empty crop %v", c.Rect)
+	}
+	if !c.Rect.In(image.Rectangle{Max: size}) {
+		return image.Point{}, fmt.Errorf("crop %v is outside of the %dx%d input", c.Rect, size.X, size.Y)
+	}
+
+	return c.Rect.Size(), nil
+}
+
+func (c Crop) Apply(ctx context.Context, src image.Image, concurrency bool) (image.Image, error) {
+	dst := newLike(src, c.Rect.Dx(), c.Rect.Dy())
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min.Add(c.Rect.Min), draw.Src)
+
+	return dst, nil
+}
+
+// Resize interpolates the input into Width x Height with Method, see interpolator.NewImage
+// either Width or Height can be 0 to keep the aspect ratio of the input
+type Resize struct {
+	Width, Height int
+	Method        string
+	Options       interpolator.Options
+}
+
+func (r Resize) Name() string {
+	return "resize"
+}
+
+// returns the size of the output for an input of size
+func (r Resize) size(size image.Point) image.Point {
+	w, h := r.Width, r.Height
+	if w == 0 && size.Y > 0 {
+		w = int(math.Round(float64(size.X) * float64(h) / float64(size.Y)))
+	} else if h == 0 && size.X > 0 {
+		h = int(math.Round(float64(size.Y) * float64(w) / float64(size.X)))
+	}
+
+	return image.Pt(w, h)
+}
+
+func (r Resize) Validate(size image.Point) (image.Point, error) {
+	if r.Width < 0 || r.Height < 0 || (r.Width == 0 && r.Height == 0) {
+		return image.Point{}, fmt.Errorf("invalid size %dx%d, expected a positive width, height or both", r.Width, r.Height)
+	}
+
+	out := r.size(size)
+	if err := interpolator.Validate(r.Method, size, out); err != nil {
+		return image.Point{}, err
+	}
+	if err := r.Options.Validate(r.Method); err != nil {
+		return image.Point{}, err
+	}
+
+	return out, nil
+}
+
+func (r Resize) Apply(ctx context.Context, src image.Image, concurrency bool) (image.Image, error) {
+	out := r.size(src.Bounds().Size())
+
+	return interpolator.NewImage(src, out.X, out.Y, r.Method, r.Options).InterpolateImage(ctx, concurrency)
+}
+
+// Rotate rotates the input around its center, sampled with Method, see interpolator.NewRotate
+type Rotate struct {
+	Rotation interpolator.Rotation
+	Method   string
+	Options  interpolator.Options
+}
+
+func (r Rotate) Name() string {
+	return "rotate"
+}
+
+func (r Rotate) Validate(size image.Point) (image.Point, error) {
+	if !interpolator.CanWarp(r.Method) {
+		return image.Point{}, fmt.Errorf("interpolation method %q can't sample rotations", r.Method)
+	}
+	if err := r.Options.Validate(r.Method); err != nil {
+		return image.Point{}, err
+	}
+
+	return r.Rotation.Size(size), nil
+}
+
+func (r Rotate) Apply(ctx context.Context, src image.Image, concurrency bool) (image.Image, error) {
+	return interpolator.NewRotate(src, r.Rotation, r.Method, r.Options).InterpolateImage(ctx, concurrency)
+}
+
+// Flip mirrors or transposes the input by Op, one of interpolator.FlipHorizontal, interpolator.FlipVertical,
+// interpolator.Transpose and interpolator.Transverse, see interpolator.Flip
+type Flip struct {
+	Op string
+}
+
+func (f Flip) Name() string {
+	return "flip"
+}
+
+func (f Flip) Validate(size image.Point) (image.Point, error) {
+	switch f.Op {
+	case interpolator.FlipHorizontal, interpolator.FlipVertical:
+		return size, nil
+	case interpolator.Transpose, interpolator.Transverse:
+		return image.Pt(size.Y, size.X), nil
+	}
+
+	return image.Point{}, fmt.Errorf("unknown flip %q, expected horizontal, vertical, transpose or transverse", f.Op)
+}
+
+func (f Flip) Apply(ctx context.Context, src image.Image, concurrency bool) (image.Image, error) {
+	return interpolator.Flip(src, f.Op), nil
+}
+
+// Sharpen sharpens the input with an unsharp mask, see filter.UnsharpMask
+// the output has 8 bits per channel
+type Sharpen struct {
+	Sharpening filter.Sharpening
+	Options    filter.Options
+}
+
+func (s Sharpen) Name() string {
+	return "sharpen"
+}
+
+func (s Sharpen) Validate(size image.Point) (image.Point, error) {
+	if s.Sharpening.Radius <= 0 || s.Sharpening.Amount < 0 || s.Sharpening.Threshold < 0 {
+		return image.Point{}, errors.New("invalid sharpening, expected a positive radius and an amount and threshold of at least 0")
+	}
+
+	return size, nil
+}
+
+func (s Sharpen) Apply(ctx context.Context, src image.Image, concurrency bool) (image.Image, error) {
+	return filter.NewUnsharpMask(interpolator.ToNRGBA(src), s.Sharpening, s.Options).ApplyContext(ctx, concurrency)
+}
+
+// Blur blurs the input with a gaussian of standard deviation Sigma in pixels, see filter.GaussianBlur
+// the output has 8 bits per channel
+type Blur struct {
+	Sigma   float64
+	Options filter.Options
+}
+
+func (b Blur) Name() string {
+	return "blur"
+}
+
+func (b Blur) Validate(size image.Point) (image.Point, error) {
+	if b.Sigma <= 0 {
+		return image.Point{}, fmt.Errorf("invalid sigma %v, expected a positive one", b.Sigma)
+	}
+
+	return size, nil
+}
+
+func (b Blur) Apply(ctx context.Context, src image.Image, concurrency bool) (image.Image, error) {
+	return filter.NewGaussianBlur(interpolator.ToNRGBA(src), b.Sigma, b.Options).ApplyContext(ctx, concurrency)
+}
+
+// Pad adds borders of Background around the input, transparent when nil
+type Pad struct {
+	Top, Right, Bottom, Left int
+	Background               color.Color
+}
+
+func (p Pad) Name() string {
+	return "pad"
+}
+
+func (p Pad) Validate(size image.Point) (image.Point, error) {
+	if p.Top < 0 || p.Right < 0 || p.Bottom < 0 || p.Left < 0 {
+		return image.Point{}, fmt.Errorf("invalid padding %d %d %d %d, expected borders of at least 0", p.Top, p.Right, p.Bottom, p.Left)
+	}
+
+	return size.Add(image.Pt(p.Left+p.Right, p.Top+p.Bottom)), nil
+}
+
+func (p Pad) Apply(ctx context.Context, src image.Image, concurrency bool) (image.Image, error) {
+	size, _ := p.Validate(src.Bounds().Size())
+
+	// gray images stay gray, unless the background is colored or transparent
+	var dst draw.Image
+	if _, ok := src.(*image.Gray); ok && p.Background != nil && isGray(p.Background) {
+		dst = image.NewGray(image.Rect(0, 0, size.X, size.Y))
+	} else if interpolator.Is16Bit(src.ColorModel()) {
+		dst = image.NewNRGBA64(image.Rect(0, 0, size.X, size.Y))
+	} else {
+		dst = image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+	}
+
+	if p.Background != nil {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(p.Background), image.Point{}, draw.Src)
+	}
+	draw.Draw(dst, src.Bounds().Sub(src.Bounds().Min).Add(image.Pt(p.Left, p.Top)), src, src.Bounds().Min, draw.Src)
+
+	return dst, nil
+}
+
+// Convert converts the input into Model, one of ModelNRGBA, ModelNRGBA64, ModelGray and ModelGray16
+type Convert struct {
+	Model string
+}
+
+func (c Convert) Name() string {
+	return "convert"
+}
+
+func (c Convert) Validate(size image.Point) (image.Point, error) {
+	switch c.Model {
+	case ModelNRGBA, ModelNRGBA64, ModelGray, ModelGray16:
+		return size, nil
+	}
+
+	return image.Point{}, fmt.Errorf("unknown color model %q, expected nrgba, nrgba64, gray or gray16", c.Model)
+}
+
+func (c Convert) Apply(ctx context.Context, src image.Image, concurrency bool) (image.Image, error) {
+	r := image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy())
+
+	var dst draw.Image
+	switch c.Model {
+	case ModelNRGBA:
+		dst = image.NewNRGBA(r)
+	case ModelNRGBA64:
+		dst = image.NewNRGBA64(r)
+	case ModelGray:
+		dst = image.NewGray(r)
+	case ModelGray16:
+		dst = image.NewGray16(r)
+	}
+	draw.Draw(dst, r, src, src.Bounds().Min, draw.Src)
+
+	return dst, nil
+}
+
+// reports whether c is an opaque gray
+func isGray(c color.Color) bool {
+	return color.NRGBAModel.Convert(color.GrayModel.Convert(c)) == color.NRGBAModel.Convert(c)
+}
+
+// returns a new w x h image of the kind of src, *image.NRGBA for kinds that can't be drawn into
+func newLike(src image.Image, w, h int) draw.Image {
+	r := image.Rect(0, 0, w, h)
+
+	switch {
+	case src.ColorModel() == color.GrayModel:
+		return image.NewGray(r)
+	case src.ColorModel() == color.Gray16Model:
+		return image.NewGray16(r)
+	case interpolator.Is16Bit(src.ColorModel()):
+		return image.NewNRGBA64(r)
+	}
+	return image.NewNRGBA(r)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"strings"
+
+	"gthub.com/obzva/image-resize/filter"
+	"gthub.com/obzva/image-resize/hexcolor"
+	"gthub.com/obzva/image-resize/imageprocessor"
+	"gthub.com/obzva/image-resize/interpolator"
+	"gthub.com/obzva/image-resize/pipeline"
+)
+
+// resizes one image, the command run without a subcommand, so that invocations from before subcommands keep working
+func runResize(args []string) error {
+	fs := flag.NewFlagSet("resize", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "usage: image-resize resize -p input [flags]\n\nResizes, flips, rotates, blurs and sharpens one image. The flags also work without the resize subcommand.\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+
+	pathPtr := fs.String("p", "", "input image path, - reads the image from stdin")
+	iFormatPtr := fs.String("if", "", "format of the input image (options: jpg, jpeg, and png), defaults to the extension of -p when omitted, or to the content of stdin with -p -")
+	jobPtr := fs.String("job", "", "JSON or YAML job file describing inputs, outputs and their operations, replaces the other flags except -dry-run and -v, same as the batch command")
+	dryRunPtr := fs.Bool("dry-run", false, "print the resolved plan of -job without processing anything, defaults to false when omitted")
+	wPtr := fs.Int("w", 0, "desired width of output image, defaults to keep the ratio of the original image when omitted (at least one of two, width or height, is required)")
+	hPtr := fs.Int("h", 0, "desired height of output image, defaults to keep the ratio of the original image when omitted (at least one of two, width or height, is required)")
+	methodPtr := fs.String("m", "nearestneighbor", "desired interpolation method, defaults to nearestneighbor (options: nearestneighbor, bilinear, bicubic, catmullrom, mitchell, bspline, hermite, cubic with -cubic-b and -cubic-c, nedi for edge-directed 2x enlargements (bicubic otherwise), seamcarve for content-aware resizing, and the pixel-art scalers scale2x, scale3x, xbr2x, xbr3x, and xbr4x, which need an output of exactly 2, 3 or 4 times the input size)")
+	cubicBPtr := fs.Float64("cubic-b", 1./3, "B parameter of the cubic method from 0 to 1, larger values blur more, defaults to 1/3 when omitted")
+	cubicCPtr := fs.Float64("cubic-c", 1./3, "C parameter of the cubic method from 0 to 1, larger values ring more, defaults to 1/3 when omitted")
+	outputPtr := fs.String("o", "", "desired output filename, - writes the image to stdout, defaults to the method name when omitted, or to stdout with -p -")
+	formatPtr := fs.String("f", "", "format of the output image (options: jpg, jpeg, and png), defaults to the extension of -o when omitted, or to the input format with -o -")
+	concurrencyPtr := fs.Bool("c", true, "concurrency mode, defaults to true when omitted")
+	protectPtr := fs.String("protect", "", "mask image of the size of the input, seam carving keeps its pixels that are neither black nor transparent, implies -m seamcarve, defaults to none when omitted")
+	removePtr := fs.String("remove", "", "mask image of the size of the input, seam carving first removes its pixels that are neither black nor transparent, implies -m seamcarve, defaults to none when omitted")
+	flipPtr := fs.String("flip", "", "comma-separated flip operations applied in order after resizing (options: horizontal, vertical, transpose, and transverse), defaults to none when omitted")
+	rotatePtr := fs.Float64("r", 0, "clockwise rotation in degrees applied after resizing and flipping, sampled with the interpolation method, defaults to 0 when omitted")
+	expandPtr := fs.Bool("expand", false, "grow the canvas to fit the whole rotated image instead of keeping its size, defaults to false when omitted")
+	bgPtr := fs.String("bg", "", "background color of rotated images and of constant edges as hex RRGGBB or RRGGBBAA, defaults to transparent when omitted")
+	edgePtr := fs.String("edge", "clamp", "how pixels outside of the input are read near its border, defaults to clamp when omitted (options: clamp, reflect, wrap, and constant)")
+	blurPtr := fs.Float64("blur", 0, "standard deviation in pixels of a gaussian blur applied after resizing, flipping and rotating, defaults to 0 (no blur) when omitted")
+	boxBlurPtr := fs.Int("box-blur", 0, "radius in pixels of a box blur applied after resizing, flipping and rotating, defaults to 0 (no blur) when omitted")
+	boxPassesPtr := fs.Int("box-passes", 3, "number of box blur passes, three come close to a gaussian blur, defaults to 3 when omitted")
+	sharpenPtr := fs.Float64("sharpen", 0, "amount of unsharp-mask sharpening applied last, after blurring, 1 doubles the contrast of detail, defaults to 0 (no sharpening) when omitted")
+	sharpenRadiusPtr := fs.Float64("sharpen-radius", 1, "radius (standard deviation of the blur) of the unsharp mask in pixels, defaults to 1 when omitted")
+	sharpenThresholdPtr := fs.Float64("sharpen-threshold", 0, "smallest difference in 8-bit color values that the unsharp mask sharpens, defaults to 0 when omitted")
+	workersPtr := fs.Int("n", 0, "number of goroutines in concurrency mode, defaults to the number of CPUs when omitted")
+	linearPtr := fs.Bool("l", false, "interpolate in linear light instead of sRGB, defaults to false when omitted")
+	verbosePtr := fs.Bool("v", false, "print how long the interpolation took on stderr, defaults to false when omitted")
+	progressPtr := fs.Bool("progress", false, "show a progress bar on stderr while interpolating, defaults to false when omitted")
+
+	fs.Parse(args)
+
+	if *jobPtr != "" {
+		return runJob(*jobPtr, *dryRunPtr, *verbosePtr)
+	}
+
+	// every flag is checked before the input is read, so that mistakes don't wait for large images to be decoded
+	if err := imageprocessor.Validate(*pathPtr, *iFormatPtr, *wPtr, *hPtr, *outputPtr, *formatPtr); err != nil {
+		return err
+	}
+
+	method := *methodPtr
+	carving := *protectPtr != "" || *removePtr != ""
+	if carving {
+		method = "seamcarve"
+	}
+	if err := interpolator.Validate(method, image.Pt(1, 1), image.Pt(1, 1)); err != nil && !errors.Is(err, interpolator.ErrScale) {
+		return err
+	}
+
+	var bg color.Color
+	if *bgPtr != "" {
+		c, err := hexcolor.Parse(*bgPtr)
+		if err != nil {
+			return err
+		}
+		bg = c
+	}
+
+	opts := interpolator.Options{Workers: *workersPtr, Linear: *linearPtr, Edge: *edgePtr, EdgeColor: bg, B: *cubicBPtr, C: *cubicCPtr}
+	if err := opts.Validate(method); err != nil {
+		return err
+	}
+
+	var flips []string
+	if *flipPtr != "" {
+		flips = strings.Split(*flipPtr, ",")
+	}
+	for _, op := range flips {
+		switch op {
+		case interpolator.FlipHorizontal, interpolator.FlipVertical, interpolator.Transpose, interpolator.Transverse:
+		default:
+			return fmt.Errorf("invalid flip operation %q, expected horizontal, vertical, transpose or transverse", op)
+		}
+	}
+
+	// rotations are sampled with the interpolation method
+	if *rotatePtr != 0 && !interpolator.CanWarp(method) {
+		return fmt.Errorf("interpolation method %q can't sample rotations", method)
+	}
+
+	if *blurPtr < 0 || *boxBlurPtr < 0 || *boxPassesPtr <= 0 {
+		return errors.New("invalid blur, expected a blur and box blur radius of at least 0 and a positive number of box passes")
+	}
+
+	sharpening := filter.Sharpening{Radius: *sharpenRadiusPtr, Amount: *sharpenPtr, Threshold: *sharpenThresholdPtr}
+	if *sharpenPtr != 0 {
+		if _, err := (pipeline.Sharpen{Sharpening: sharpening}).Validate(image.Point{}); err != nil {
+			return err
+		}
+	}
+
+	if *progressPtr {
+		opts.Progress = progressBar
+	}
+	if *verbosePtr {
+		opts.Observer = func(s interpolator.Stats) {
+			fmt.Fprintln(os.Stderr, s)
+		}
+	}
+
+	ip := imageprocessor.NewWithFormats(*pathPtr, *iFormatPtr, *wPtr, *hPtr, *methodPtr, *concurrencyPtr, *outputPtr, *formatPtr, opts)
+
+	if carving {
+		if err := ip.Carve(*protectPtr, *removePtr); err != nil {
+			return err
+		}
+	}
+
+	for _, op := range flips {
+		ip.Flip(op)
+	}
+
+	if *rotatePtr != 0 {
+		ip.Rotate(interpolator.Rotation{Degrees: *rotatePtr, Expand: *expandPtr, Background: bg})
+	}
+
+	if *blurPtr > 0 {
+		ip.Blur(*blurPtr)
+	}
+	if *boxBlurPtr > 0 {
+		ip.BoxBlur(*boxBlurPtr, *boxPassesPtr)
+	}
+
+	if *sharpenPtr != 0 {
+		ip.Sharpen(sharpening)
+	}
+
+	return ip.CreateImageFile()
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gthub.com/obzva/image-resize/cache"
+	"gthub.com/obzva/image-resize/imgproxy"
+	"gthub.com/obzva/image-resize/interpolator"
+	"gthub.com/obzva/image-resize/parallel"
+	"gthub.com/obzva/image-resize/pipeline"
+)
+
+// serves imgproxy-style URLs, reading local:// sources under a root directory
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), "usage: image-resize serve [flags]\n\nServes imgproxy-style URLs such as /insecure/rs:fill:300:200/plain/local:///photo.jpg@png, reading local:// sources under -root.\nUnsigned URLs may leave out insecure when they start with an option or with plain.\nOutputs are written as jpeg or png, other formats such as @webp are rejected with 415.\n\nflags:\n")
+		flags.PrintDefaults()
+	}
+	addrPtr := flags.String("addr", ":8080", "address to listen on, defaults to :8080 when omitted")
+	rootPtr := flags.String("root", ".", "directory local:// sources are read from, defaults to the working directory when omitted")
+	workersPtr := flags.Int("n", 0, "number of goroutines shared by all requests, defaults to the number of CPUs when omitted")
+	cacheDirPtr := flags.String("cache-dir", "", "directory results are cached in, keyed by the content of their source and their options, defaults to no caching when omitted")
+	cacheSizePtr := flags.Int64("cache-size", 1024, "largest size of -cache-dir in MiB, the least recently used results are evicted beyond it, defaults to 1024 when omitted")
+	maxPixelsPtr := flags.Int("max-pixels", 50_000_000, "largest number of pixels of an output, larger ones are rejected with 413, defaults to 50 million when omitted")
+	maxSourcePixelsPtr := flags.Int("max-source-pixels", 50_000_000, "largest number of pixels of a source, larger ones are rejected with 413 before decoding, defaults to 50 million when omitted")
+	maxSourceSizePtr := flags.Int64("max-source-size", 64, "largest size of a source file in MiB, larger ones are rejected with 413 before reading, defaults to 64 when omitted")
+
+	flags.Parse(args)
+
+	root, err := filepath.Abs(*rootPtr)
+	if err != nil {
+		return err
+	}
+	// sources are resolved before they are checked against the root, and so is the root
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return err
+	}
+
+	if *maxPixelsPtr <= 0 {
+		return fmt.Errorf("invalid -max-pixels %d, expected a positive number", *maxPixelsPtr)
+	}
+	if *maxSourcePixelsPtr <= 0 {
+		return fmt.Errorf("invalid -max-source-pixels %d, expected a positive number", *maxSourcePixelsPtr)
+	}
+	if *maxSourceSizePtr <= 0 {
+		return fmt.Errorf("invalid -max-source-size %d, expected a positive number", *maxSourceSizePtr)
+	}
+
+	// requests share the goroutines, so that concurrent ones don't multiply them
+	pool := parallel.NewPool(*workersPtr)
+	defer pool.Close()
+
+	s := &server{
+		root:            root,
+		maxPixels:       *maxPixelsPtr,
+		maxSourcePixels: *maxSourcePixelsPtr,
+		maxSourceSize:   *maxSourceSizePtr << 20,
+		opts:            interpolator.Options{Pool: pool},
+	}
+	if *cacheDirPtr != "" {
+		if s.cache, err = cache.New(*cacheDirPtr, *cacheSizePtr<<20); err != nil {
+			return err
+		}
+	}
+	log.Printf("serving %s on %s", root, *addrPtr)
+
+	return http.ListenAndServe(*addrPtr, s)
+}
+
+// resizes the local:// sources of imgproxy-style URLs
+type server struct {
+	root            string       // absolute, with its symlinks resolved
+	maxPixels       int          // largest number of pixels of an output
+	maxSourcePixels int          // largest number of pixels of a source
+	maxSourceSize   int64        // largest size of a source file in bytes
+	cache           *cache.Cache // encoded results, nil without caching
+	opts            interpolator.Options
+}
+
+// errDecode is returned by render when the source can't be decoded past its header
+var errDecode = errors.New("can't decode the source")
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	o, err := imgproxy.Parse(r.URL.Path)
+	if errors.Is(err, imgproxy.ErrFormat) {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p, err := o.Path()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// sources are relative to the root, and can't leave it, neither by their path nor through symlinks
+	path := filepath.Join(s.root, filepath.FromSlash(p))
+	if !s.contains(path) {
+		http.Error(w, "source outside of the root", http.StatusForbidden)
+		return
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !s.contains(path) {
+		http.Error(w, "source outside of the root", http.StatusForbidden)
+		return
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if info.Size() > s.maxSourceSize {
+		http.Error(w, fmt.Sprintf("%s is larger than %d bytes", p, s.maxSourceSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// the ETag is derived from the metadata of the source and the options, so that conditional requests
+	// are answered without reading the source
+	// there is no Last-Modified, which would be the one of the source whatever the options
+	etag := cache.Key([]byte(path), fmt.Appendf(nil, "%d %d %+v", info.Size(), info.ModTime().UnixNano(), *o))
+	w.Header().Set("ETag", `"`+etag+`"`)
+	if cache.NotModified(r, etag, time.Time{}) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	// the whole source is hashed into the key of the result anyway
+	data, err := readFile(path, s.maxSourceSize)
+	if errors.Is(err, errTooLarge) {
+		http.Error(w, fmt.Sprintf("%s is larger than %d bytes", p, s.maxSourceSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the source and the output are checked against the header, so that too large ones are rejected before decoding anything
+	config, iFormat, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%s: %v", p, err), http.StatusUnprocessableEntity)
+		return
+	}
+	if config.Width > 0 && config.Height > s.maxSourcePixels/config.Width {
+		http.Error(w, fmt.Sprintf("%dx%d source is larger than %d pixels", config.Width, config.Height, s.maxSourcePixels), http.StatusRequestEntityTooLarge)
+		return
+	}
+	crop, oW, oH := o.Plan(config.Width, config.Height)
+	if oW > 0 && oH > s.maxPixels/oW {
+		http.Error(w, fmt.Sprintf("%dx%d output is larger than %d pixels", oW, oH, s.maxPixels), http.StatusRequestEntityTooLarge)
+		return
+	}
+	pl := s.pipeline(image.Pt(config.Width, config.Height), crop, oW, oH, o.Method)
+	if _, err := pl.Validate(image.Pt(config.Width, config.Height)); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	format := o.Format
+	if format == "" {
+		format = iFormat
+	}
+	quality := 0
+	switch format {
+	case "jpeg":
+		quality = o.Quality
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+	case "png":
+	default:
+		http.Error(w, fmt.Sprintf("can't write %s images, add @jpeg or @png to the source", iFormat), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// results are addressed by the content of their source and by the normalized options they are made with,
+	// so that URLs spelling the same output differently share it
+	key := cache.Key(data, fmt.Appendf(nil, "%v %dx%d %s %s %d", crop, oW, oH, o.Method, format, quality))
+
+	var out []byte
+	if s.cache != nil {
+		out, _ = s.cache.Get(key)
+	}
+	if out == nil {
+		out, err = s.render(r.Context(), data, pl, format, quality)
+		switch {
+		case errors.Is(err, context.Canceled):
+			// the client is gone, there is nobody to answer
+			return
+		case errors.Is(err, errDecode):
+			http.Error(w, fmt.Sprintf("%s: %v", p, err), http.StatusUnprocessableEntity)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if s.cache != nil {
+			if err := s.cache.Put(key, out); err != nil {
+				log.Printf("%s: %v", r.URL.Path, err)
+			}
+		}
+	}
+
+	// answers HEAD and range requests
+	w.Header().Set("Content-Type", "image/"+format)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(out))
+}
+
+// reports whether path is the root or lies under it
+func (s *server) contains(path string) bool {
+	rel, err := filepath.Rel(s.root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// errTooLarge is returned by readFile for files larger than its limit
+var errTooLarge = errors.New("file too large")
+
+// reads the file at path, or returns errTooLarge when it has more than limit bytes
+// the size is checked while reading, so that files growing after a stat are still capped
+func readFile(path string, limit int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errTooLarge
+	}
+
+	return data, nil
+}
+
+// returns the steps cropping a source of size to crop and resizing it into oW x oH with method
+func (s *server) pipeline(size image.Point, crop image.Rectangle, oW, oH int, method string) *pipeline.Pipeline {
+	var steps []pipeline.Step
+	if crop != image.Rect(0, 0, size.X, size.Y) {
+		steps = append(steps, pipeline.Crop{Rect: crop})
+	}
+	if image.Pt(oW, oH) != crop.Size() {
+		steps = append(steps, pipeline.Resize{Width: oW, Height: oH, Method: method, Options: s.opts})
+	}
+
+	return pipeline.New(steps...)
+}
+
+// decodes src, runs pl on it, and encodes the result in format
+// quality is the quality of jpeg outputs
+func (s *server) render(ctx context.Context, src []byte, pl *pipeline.Pipeline, format string, quality int) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDecode, err)
+	}
+
+	out, _, err := pl.Run(ctx, img, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&b, out, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(&b, out)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gthub.com/obzva/image-resize/cache"
+	"gthub.com/obzva/image-resize/interpolator"
+	"gthub.com/obzva/image-resize/parallel"
+)
+
+// returns a server reading a 40x20 source.png from a temporary root
+func testServer(t *testing.T, maxPixels int) *server {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(filepath.Join(root, "source.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewNRGBA(image.Rect(0, 0, 40, 20))); err != nil {
+		t.Fatal(err)
+	}
+
+	pool := parallel.NewPool(2)
+	t.Cleanup(pool.Close)
+
+	return &server{root: root, maxPixels: maxPixels, maxSourcePixels: 1 << 20, maxSourceSize: 1 << 20, opts: interpolator.Options{Pool: pool}}
+}
+
+func TestServe(t *testing.T) {
+	s := testServer(t, 1000)
+
+	// symlinks are followed within the root only
+	outside := filepath.Join(t.TempDir(), "outside.png")
+	if err := os.WriteFile(outside, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(s.root, "outside.png")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("source.png", filepath.Join(s.root, "inside.png")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		path   string
+		status int
+	}{
+		{"/insecure/rs:force:20:10/plain/local:///source.png", http.StatusOK},
+		{"/insecure/rs:force:40:25:1/plain/local:///source.png", http.StatusOK},
+		{"/insecure/rs:force:40:26:1/plain/local:///source.png", http.StatusRequestEntityTooLarge},
+		{"/insecure/rs:force:100:100:1/plain/local:///source.png", http.StatusRequestEntityTooLarge},
+		{"/insecure/rs:force:20:10/plain/local:///missing.png", http.StatusNotFound},
+		{"/insecure/rs:force:20:10/plain/local:///../source.png", http.StatusForbidden},
+		{"/insecure/rs:force:20:10/plain/local:///inside.png", http.StatusOK},
+		{"/insecure/rs:force:20:10/plain/local:///outside.png", http.StatusForbidden},
+		{"/insecure/rs:unknown:20:10/plain/local:///source.png", http.StatusBadRequest},
+		{"/rs:force:20:10/plain/local:///source.png", http.StatusOK},
+		{"/rs:force:20:10/plain/local:///source.png@webp", http.StatusUnsupportedMediaType},
+	} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if rec.Code != c.status {
+			t.Errorf("%s: expected status %d but instead got %d: %s", c.path, c.status, rec.Code, rec.Body)
+			continue
+		}
+		if c.status != http.StatusOK {
+			continue
+		}
+
+		img, err := png.Decode(rec.Body)
+		if err != nil {
+			t.Errorf("%s: %v", c.path, err)
+		} else if size := img.Bounds().Size(); size.X*size.Y > 1000 {
+			t.Errorf("%s: expected at most 1000 pixels but instead got %v", c.path, size)
+		}
+	}
+}
+
+func TestServeSource(t *testing.T) {
+	s := testServer(t, 1000)
+
+	// the header of a truncated source is fine, its pixels are not
+	var b bytes.Buffer
+	noise := image.NewGray(image.Rect(0, 0, 40, 20))
+	for i := range noise.Pix {
+		noise.Pix[i] = uint8(i * 97)
+	}
+	if err := png.Encode(&b, noise); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.root, "truncated.png"), b.Bytes()[:b.Len()/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name            string
+		path            string
+		maxSourcePixels int
+		maxSourceSize   int64
+		status          int
+	}{
+		{"within the limits", "/insecure/rs:force:20:10/plain/local:///source.png", 800, 1 << 20, http.StatusOK},
+		{"too many pixels", "/insecure/rs:force:20:10/plain/local:///source.png", 799, 1 << 20, http.StatusRequestEntityTooLarge},
+		{"too many bytes", "/insecure/rs:force:20:10/plain/local:///source.png", 800, 10, http.StatusRequestEntityTooLarge},
+		{"truncated", "/insecure/rs:force:20:10/plain/local:///truncated.png", 800, 1 << 20, http.StatusUnprocessableEntity},
+	} {
+		s.maxSourcePixels, s.maxSourceSize = c.maxSourcePixels, c.maxSourceSize
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if rec.Code != c.status {
+			t.Errorf("%s: expected status %d but instead got %d: %s", c.name, c.status, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestServeCanceled(t *testing.T) {
+	s := testServer(t, 1000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// nobody is left to read an error
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/insecure/rs:force:20:10/plain/local:///source.png", nil).WithContext(ctx))
+	if rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "" {
+		t.Errorf("expected no response but instead got %d with %q", rec.Code, rec.Body)
+	}
+}
+
+func TestServeCache(t *testing.T) {
+	s := testServer(t, 1000)
+
+	c, err := cache.New(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.cache = c
+
+	// counts the interpolations that actually run
+	runs := 0
+	s.opts.Observer = func(interpolator.Stats) {
+		runs++
+	}
+
+	get := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := get("/insecure/rs:force:20:10/plain/local:///source.png", nil)
+	// the modification time of the source is the same whatever the options, so there is no Last-Modified
+	etag, lastModified := first.Header().Get("ETag"), first.Header().Get("Last-Modified")
+	if first.Code != http.StatusOK || etag == "" || lastModified != "" {
+		t.Fatalf("expected status 200 with an ETag and no Last-Modified but instead got %d, %q and %q", first.Code, etag, lastModified)
+	}
+
+	// the same output spelled differently is served from the cache
+	second := get("/insecure/rt:force/w:20/h:10/plain/local:///source.png", nil)
+	if second.Code != http.StatusOK || second.Header().Get("ETag") != etag || !bytes.Equal(second.Body.Bytes(), first.Body.Bytes()) {
+		t.Errorf("expected the cached result with ETag %s but instead got %d with ETag %s", etag, second.Code, second.Header().Get("ETag"))
+	}
+	if runs != 1 {
+		t.Errorf("expected 1 interpolation but instead got %d", runs)
+	}
+	if c.Size() != int64(first.Body.Len()) {
+		t.Errorf("expected a cache of %d bytes but instead got %d", first.Body.Len(), c.Size())
+	}
+
+	for name, header := range map[string]http.Header{
+		"If-None-Match":          {"If-None-Match": {etag}},
+		"If-None-Match list":     {"If-None-Match": {`"other", ` + etag}},
+		"If-None-Match wildcard": {"If-None-Match": {"*"}},
+	} {
+		rec := get("/insecure/rs:force:20:10/plain/local:///source.png", header)
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+			t.Errorf("%s: expected status 304 with ETag %s and no body but instead got %d with ETag %s and %d bytes", name, etag, rec.Code, rec.Header().Get("ETag"), rec.Body.Len())
+		}
+	}
+
+	for name, header := range map[string]http.Header{
+		"stale If-None-Match": {"If-None-Match": {`"other"`}},
+		"If-Modified-Since":   {"If-Modified-Since": {time.Now().UTC().Format(http.TimeFormat)}},
+	} {
+		if rec := get("/insecure/rs:force:20:10/plain/local:///source.png", header); rec.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200 but instead got %d", name, rec.Code)
+		}
+	}
+
+	// another output and another source content have other keys
+	other := get("/insecure/rs:force:10:5/plain/local:///source.png", nil)
+	if other.Header().Get("ETag") == etag {
+		t.Error("expected another output to have another ETag but instead got the same")
+	}
+
+	// conditional requests are answered from the metadata of the source, without reading it
+	path := filepath.Join(s.root, "source.png")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, info.Size()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get("/insecure/rs:force:20:10/plain/local:///source.png", http.Header{"If-None-Match": {etag}}); rec.Code != http.StatusNotModified {
+		t.Errorf("expected status 304 without reading the source but instead got %d", rec.Code)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = png.Encode(f, image.NewGray(image.Rect(0, 0, 40, 20)))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := get("/insecure/rs:force:20:10/plain/local:///source.png", http.Header{"If-None-Match": {etag}}); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("expected status 200 with a new ETag once the source changed but instead got %d with ETag %s", rec.Code, rec.Header().Get("ETag"))
+	}
+	if runs != 3 {
+		t.Errorf("expected 3 interpolations but instead got %d", runs)
+	}
+}